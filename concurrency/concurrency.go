@@ -0,0 +1,92 @@
+package concurrency
+
+import (
+	"github.com/reeveci/reeve-lib/schema"
+)
+
+type Action string
+
+// Run the pipeline now
+const ACTION_RUN Action = "run"
+
+// Keep the pipeline enqueued until the pipelines in Blocking finished
+const ACTION_QUEUE Action = "queue"
+
+// Do not run the pipeline, its status should become STATUS_SKIPPED
+const ACTION_SKIP Action = "skip"
+
+type Decision struct {
+	Action Action
+	// Interpolated concurrency group of the pipeline, empty if the pipeline has none
+	Group string
+	// Activity IDs of the pipelines which should be cancelled
+	Cancel []string
+	// Activity IDs of the pipelines the new pipeline has to wait for
+	Blocking []string
+}
+
+// Decide determines whether a pipeline may run, given the pipelines which are currently enqueued or running.
+// The candidate itself may be contained in active, it is recognized by its ActivityID.
+// A candidate without enqueue time is treated as enqueued after all other pipelines.
+// Finished pipelines and pipelines of other groups are ignored, as well as pipelines whose group cannot be determined.
+//
+// Only running pipelines and pipelines enqueued before the candidate are blocked or cancelled by it,
+// so pipelines of a group run in the order they were enqueued.
+func Decide(candidate schema.PipelineStatus, active []schema.PipelineStatus) (Decision, error) {
+	group, err := candidate.Pipeline.ConcurrencyGroup()
+	if err != nil {
+		return Decision{}, err
+	}
+	decision := Decision{Action: ACTION_RUN, Group: group}
+	if group == "" {
+		return decision, nil
+	}
+
+	var enqueued, running []string
+	for _, status := range active {
+		if status.ActivityID == candidate.ActivityID || status.Finished() {
+			continue
+		}
+		if other, err := status.Pipeline.ConcurrencyGroup(); err != nil || other != group {
+			continue
+		}
+
+		if status.Running() {
+			running = append(running, status.ActivityID)
+		} else if enqueuedBefore(status, candidate) {
+			enqueued = append(enqueued, status.ActivityID)
+		}
+	}
+
+	switch candidate.Pipeline.Concurrency.GetPolicy() {
+	case schema.CONCURRENCY_CANCEL_IN_PROGRESS:
+		decision.Cancel = append(running, enqueued...)
+
+	case schema.CONCURRENCY_SKIP_IF_RUNNING:
+		if len(running) > 0 {
+			decision.Action = ACTION_SKIP
+		}
+
+	default:
+		if blocking := append(running, enqueued...); len(blocking) > 0 {
+			decision.Action = ACTION_QUEUE
+			decision.Blocking = blocking
+		}
+	}
+
+	return decision, nil
+}
+
+// enqueuedBefore orders pipelines by enqueue time and then by activity ID, pipelines without enqueue time come last.
+func enqueuedBefore(status, candidate schema.PipelineStatus) bool {
+	switch {
+	case candidate.Enqueued.IsZero():
+		return true
+	case status.Enqueued.IsZero():
+		return false
+	case status.Enqueued.Equal(candidate.Enqueued):
+		return status.ActivityID < candidate.ActivityID
+	default:
+		return status.Enqueued.Before(candidate.Enqueued)
+	}
+}
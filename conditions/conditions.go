@@ -1,6 +1,12 @@
 package conditions
 
-import "github.com/reeveci/reeve-lib/schema"
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/reeveci/reeve-lib/schema"
+)
 
 func ApplyDefaults(conditions *map[string]schema.Condition, defaults map[string]schema.Condition) {
 	if *conditions == nil {
@@ -28,3 +34,21 @@ func Check(facts map[string]schema.Fact, conditions map[string]schema.Condition,
 
 	return true, nil
 }
+
+// CheckReason works like Check, but also returns a reason if a condition is not met, e.g. for StepResult.SkipReason.
+// Conditions are checked in the order of their keys, so the reason is deterministic.
+func CheckReason(facts map[string]schema.Fact, conditions map[string]schema.Condition, env map[string]schema.Env, vars map[string]schema.Var) (bool, string, error) {
+	for _, key := range slices.Sorted(maps.Keys(conditions)) {
+		if key != "" {
+			ok, err := conditions[key].Check(key, facts, env, vars)
+			if err != nil {
+				return false, "", err
+			}
+			if !ok {
+				return false, fmt.Sprintf(`condition "%s" not met`, key), nil
+			}
+		}
+	}
+
+	return true, "", nil
+}
@@ -0,0 +1,224 @@
+package filter
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const MASK = "***"
+
+// Lines of secrets spanning multiple lines which are shorter than this are not masked on their own,
+// since short lines like "}" would otherwise mask unrelated output all over the log
+const MIN_SECRET_LINE_LENGTH = 4
+
+// Lines longer than this are masked and written in parts, holding back enough to mask secrets at the end
+const MAX_LINE_LENGTH = 64 * 1024
+
+// SecretVariants returns the secret along with its common encodings (base64, URL-escaped, JSON-escaped).
+// Secrets spanning multiple lines are masked by the encodings of the whole secret, which fit on a single line,
+// and by their lines, because filters work line by line. Lines shorter than MIN_SECRET_LINE_LENGTH are skipped for such secrets.
+func SecretVariants(secret string) []string {
+	result := make([]string, 0, 8)
+	add := func(value string) {
+		if value != "" && !strings.ContainsAny(value, "\r\n") && !slices.Contains(result, value) {
+			result = append(result, value)
+		}
+	}
+	addEncodings := func(value string) {
+		add(value)
+		add(base64.StdEncoding.EncodeToString([]byte(value)))
+		add(base64.RawStdEncoding.EncodeToString([]byte(value)))
+		add(base64.URLEncoding.EncodeToString([]byte(value)))
+		add(base64.RawURLEncoding.EncodeToString([]byte(value)))
+		add(url.QueryEscape(value))
+		add(url.PathEscape(value))
+		add(jsonEscape(value, false))
+		add(jsonEscape(value, true))
+	}
+
+	addEncodings(secret)
+
+	lines := strings.FieldsFunc(secret, func(r rune) bool { return r == '\n' || r == '\r' })
+	if len(lines) > 1 {
+		for _, line := range lines {
+			if len(line) >= MIN_SECRET_LINE_LENGTH {
+				addEncodings(line)
+			}
+		}
+	}
+
+	return result
+}
+
+// jsonEscape returns the content of the JSON string for value, escapeHTML matches the output of encoding/json.
+func jsonEscape(value string, escapeHTML bool) string {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(escapeHTML)
+	if err := encoder.Encode(value); err != nil {
+		return ""
+	}
+	escaped := strings.TrimSuffix(buffer.String(), "\n")
+	return escaped[1 : len(escaped)-1]
+}
+
+// NewMasker returns a filter for LineFilter which replaces all secrets and their variants by MASK.
+func NewMasker(secrets []string) func(line string) string {
+	mask, _ := newMasker(secrets)
+	return mask
+}
+
+// newMasker also returns the variants being masked, longest first.
+func newMasker(secrets []string) (func(line string) string, []string) {
+	variants := make([]string, 0, len(secrets)*8)
+	for _, secret := range secrets {
+		for _, variant := range SecretVariants(secret) {
+			if !slices.Contains(variants, variant) {
+				variants = append(variants, variant)
+			}
+		}
+	}
+	if len(variants) == 0 {
+		return func(line string) string { return line }, variants
+	}
+
+	// longer variants first, so that a secret containing another one is masked as a whole
+	slices.SortStableFunc(variants, func(a, b string) int { return len(b) - len(a) })
+
+	pairs := make([]string, 0, 2*len(variants))
+	for _, variant := range variants {
+		pairs = append(pairs, variant, MASK)
+	}
+	replacer := strings.NewReplacer(pairs...)
+
+	return replacer.Replace, variants
+}
+
+// MaskWriter returns a writer which masks all secrets before writing to target, e.g. a logs.LogWriter.
+//
+// Output is passed on line by line like LineFilter, so secrets spanning multiple writes are masked as well,
+// and incomplete lines are only written once they are terminated or the writer is closed.
+// Lines longer than MAX_LINE_LENGTH are written in parts.
+// Close must be called to flush the last line, it does not close target.
+func MaskWriter(target io.Writer, secrets []string) io.WriteCloser {
+	mask, variants := newMasker(secrets)
+	holdback := 0
+	if len(variants) > 0 {
+		holdback = len(variants[0]) - 1
+	}
+	return &maskWriter{target: target, mask: mask, variants: variants, holdback: holdback}
+}
+
+type maskWriter struct {
+	target   io.Writer
+	mask     func(line string) string
+	variants []string
+	holdback int
+
+	buffer  []byte
+	afterCR bool
+	err     error
+	lock    sync.Mutex
+}
+
+func (m *maskWriter) Write(b []byte) (int, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.err != nil {
+		return 0, m.err
+	}
+	m.buffer = append(m.buffer, b...)
+
+	for len(m.buffer) > 0 {
+		if m.afterCR {
+			m.afterCR = false
+			if m.buffer[0] == '\n' {
+				// the line was already terminated by the preceding carriage return
+				m.buffer = m.buffer[1:]
+				continue
+			}
+		}
+
+		i := bytes.IndexAny(m.buffer, "\r\n")
+		if i < 0 {
+			break
+		}
+
+		advance := i + 1
+		if m.buffer[i] == '\r' {
+			if len(m.buffer) == i+1 {
+				m.afterCR = true
+			} else if m.buffer[i+1] == '\n' {
+				advance += 1
+			}
+		}
+
+		line := string(m.buffer[:i])
+		m.buffer = m.buffer[advance:]
+		if err := m.write(m.mask(line + "\n")); err != nil {
+			return 0, err
+		}
+	}
+
+	if len(m.buffer) > MAX_LINE_LENGTH {
+		cut := m.safeCut(string(m.buffer), len(m.buffer)-m.holdback)
+		part := string(m.buffer[:cut])
+		m.buffer = slices.Clone(m.buffer[cut:])
+		if err := m.write(m.mask(part)); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(b), nil
+}
+
+func (m *maskWriter) Close() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.err != nil {
+		return m.err
+	}
+	if len(m.buffer) > 0 {
+		line := string(m.buffer)
+		m.buffer = nil
+		return m.write(m.mask(line + "\n"))
+	}
+	return nil
+}
+
+func (m *maskWriter) write(data string) error {
+	if data == "" {
+		return nil
+	}
+	_, m.err = io.WriteString(m.target, data)
+	return m.err
+}
+
+// safeCut moves cut before any variant occurring across it, so that no secret is split between two parts.
+func (m *maskWriter) safeCut(data string, cut int) int {
+	for moved := true; moved; {
+		moved = false
+		for _, variant := range m.variants {
+			// every occurrence within this window starts before and ends after cut
+			start := max(cut-len(variant)+1, 0)
+			end := min(cut+len(variant)-1, len(data))
+			if start >= end {
+				continue
+			}
+			if i := strings.Index(data[start:end], variant); i >= 0 {
+				cut = start + i
+				moved = true
+				break
+			}
+		}
+	}
+	return cut
+}
@@ -0,0 +1,111 @@
+package filter
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func maskWrites(t *testing.T, secrets []string, writes ...string) string {
+	t.Helper()
+
+	var output bytes.Buffer
+	writer := MaskWriter(&output, secrets)
+	for _, write := range writes {
+		if _, err := writer.Write([]byte(write)); err != nil {
+			t.Fatalf("unexpected write error - %s", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("unexpected close error - %s", err)
+	}
+	return output.String()
+}
+
+func TestMaskWriterSplitWrites(t *testing.T) {
+	input := "pass hunter2 done\n"
+	for i := 1; i < len(input); i++ {
+		result := maskWrites(t, []string{"hunter2"}, input[:i], input[i:])
+		if result != "pass *** done\n" {
+			t.Errorf("split at %v: got %q", i, result)
+		}
+	}
+}
+
+func TestMaskWriterLongLine(t *testing.T) {
+	secret := "hunter2"
+	for offset := 0; offset < 32; offset++ {
+		line := strings.Repeat("x", MAX_LINE_LENGTH-offset) + secret + strings.Repeat("y", 8)
+
+		for _, writes := range [][]string{
+			{line + "\n"},
+			{line[:MAX_LINE_LENGTH-offset+3], line[MAX_LINE_LENGTH-offset+3:], "\n"},
+		} {
+			result := maskWrites(t, []string{secret}, writes...)
+			expected := strings.Repeat("x", MAX_LINE_LENGTH-offset) + MASK + strings.Repeat("y", 8) + "\n"
+			if result != expected {
+				t.Errorf("offset %v, %v writes: secret not masked as a whole", offset, len(writes))
+			}
+		}
+	}
+
+	// the log continues after an overlong line
+	result := maskWrites(t, []string{"hunter2"}, strings.Repeat("x", 70000), "\npass hunter2\n")
+	if !strings.HasSuffix(result, "\npass ***\n") || len(result) != 70000+len("\npass ***\n") {
+		t.Errorf("unexpected output after long line - %q", result[max(len(result)-20, 0):])
+	}
+}
+
+func TestMaskWriterLineEndings(t *testing.T) {
+	input := "a hunter2\r\nb hunter2\rc hunter2\nd hunter2"
+	expected := "a ***\nb ***\nc ***\nd ***\n"
+	for i := 1; i < len(input); i++ {
+		if result := maskWrites(t, []string{"hunter2"}, input[:i], input[i:]); result != expected {
+			t.Errorf("split at %v: got %q", i, result)
+		}
+	}
+}
+
+func TestMaskWriterEncodings(t *testing.T) {
+	secret := "-----BEGIN KEY-----\nqu\"ote<tag>&amp\nMIIEvQIBADANBgkqhkiG9w0BAQEFAASC\n-----END KEY-----"
+
+	htmlEscaped, _ := json.Marshal(secret)
+	var plainEscaped bytes.Buffer
+	encoder := json.NewEncoder(&plainEscaped)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(secret)
+
+	encodings := map[string]string{
+		"base64":         base64.StdEncoding.EncodeToString([]byte(secret)),
+		"base64 raw":     base64.RawStdEncoding.EncodeToString([]byte(secret)),
+		"base64 url":     base64.URLEncoding.EncodeToString([]byte(secret)),
+		"base64 raw url": base64.RawURLEncoding.EncodeToString([]byte(secret)),
+		"query":          url.QueryEscape(secret),
+		"path":           url.PathEscape(secret),
+		"json":           strings.Trim(strings.TrimSpace(plainEscaped.String()), `"`),
+		"json html":      strings.Trim(string(htmlEscaped), `"`),
+	}
+
+	for name, encoded := range encodings {
+		result := maskWrites(t, []string{secret}, "value: "+encoded+" end\n")
+		if result != "value: "+MASK+" end\n" {
+			t.Errorf("%s: got %q", name, result)
+		}
+	}
+
+	for _, line := range strings.Split(secret, "\n") {
+		result := maskWrites(t, []string{secret}, "value: "+line+" end\n")
+		if result != "value: "+MASK+" end\n" {
+			t.Errorf("line %q: got %q", line, result)
+		}
+	}
+}
+
+func TestSecretVariantsShortLines(t *testing.T) {
+	if result := NewMasker([]string{"a\nb"})("alpha beta\n"); result != "alpha beta\n" {
+		t.Errorf("short lines of a multi-line secret were masked - %q", result)
+	}
+}
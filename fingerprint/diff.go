@@ -0,0 +1,111 @@
+package fingerprint
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+
+	"github.com/reeveci/reeve-lib/schema"
+)
+
+type ChangeKind string
+
+const CHANGE_ADDED ChangeKind = "added"
+const CHANGE_REMOVED ChangeKind = "removed"
+const CHANGE_CHANGED ChangeKind = "changed"
+
+type Change struct {
+	// Location of the change, e.g. `steps.build.when.branch` or `env.REGISTRY`
+	Path string
+	Kind ChangeKind
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s %s", c.Path, c.Kind)
+}
+
+// Diff reports all structural changes between two pipelines, ordered by path.
+//
+// Steps are matched by name (unnamed steps by index) and are addressed by name, e.g. `steps.build.task`.
+// If the order of the remaining steps changed, `steps` is reported as changed.
+// Objects are compared member by member, lists are compared as a whole.
+// Secret env values are ignored like in Canonical.
+func Diff(old, new schema.Pipeline) ([]Change, error) {
+	oldTree, err := normalize(old)
+	if err != nil {
+		return nil, err
+	}
+	newTree, err := normalize(new)
+	if err != nil {
+		return nil, err
+	}
+
+	oldSteps, oldOrder := stepsByName(oldTree)
+	newSteps, newOrder := stepsByName(newTree)
+
+	changes := make([]Change, 0)
+	diff("", oldTree, newTree, &changes)
+	diff("steps", oldSteps, newSteps, &changes)
+
+	oldOrder = slices.DeleteFunc(oldOrder, func(name string) bool { _, ok := newSteps[name]; return !ok })
+	newOrder = slices.DeleteFunc(newOrder, func(name string) bool { _, ok := oldSteps[name]; return !ok })
+	if !slices.Equal(oldOrder, newOrder) {
+		changes = append(changes, Change{Path: "steps", Kind: CHANGE_CHANGED})
+	}
+
+	slices.SortFunc(changes, func(a, b Change) int {
+		switch {
+		case a.Path < b.Path:
+			return -1
+		case a.Path > b.Path:
+			return 1
+		default:
+			return 0
+		}
+	})
+	return changes, nil
+}
+
+// stepsByName removes the steps from the tree and returns them keyed by name.
+func stepsByName(tree any) (map[string]any, []string) {
+	object, _ := tree.(map[string]any)
+	steps, _ := object["steps"].([]any)
+	delete(object, "steps")
+
+	result := make(map[string]any, len(steps))
+	order := make([]string, 0, len(steps))
+	for i, step := range steps {
+		name, _ := step.(map[string]any)["name"].(string)
+		if _, ok := result[name]; name == "" || ok {
+			name = fmt.Sprintf("#%v", i)
+		}
+		result[name] = step
+		order = append(order, name)
+	}
+	return result, order
+}
+
+func diff(path string, old, new any, changes *[]Change) {
+	oldObject, oldOk := old.(map[string]any)
+	newObject, newOk := new.(map[string]any)
+	if !oldOk || !newOk {
+		if !reflect.DeepEqual(old, new) {
+			*changes = append(*changes, Change{Path: path, Kind: CHANGE_CHANGED})
+		}
+		return
+	}
+
+	for _, key := range sortedKeys(oldObject) {
+		if _, ok := newObject[key]; !ok {
+			*changes = append(*changes, Change{Path: schema.KeyPath(path, key), Kind: CHANGE_REMOVED})
+		}
+	}
+	for _, key := range sortedKeys(newObject) {
+		oldValue, ok := oldObject[key]
+		if !ok {
+			*changes = append(*changes, Change{Path: schema.KeyPath(path, key), Kind: CHANGE_ADDED})
+			continue
+		}
+		diff(schema.KeyPath(path, key), oldValue, newObject[key], changes)
+	}
+}
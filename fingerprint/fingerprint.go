@@ -0,0 +1,107 @@
+package fingerprint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/reeveci/reeve-lib/schema"
+)
+
+const ALGORITHM = "sha256"
+
+// Canonical returns a serialization of the pipeline which only depends on its contents.
+//
+// Map keys are sorted, unset and empty values are omitted and secret env values are removed,
+// so that changing a secret does not change the serialization.
+func Canonical(pipeline schema.Pipeline) ([]byte, error) {
+	tree, err := normalize(pipeline)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(tree)
+}
+
+// Fingerprint returns a stable hash of the canonical serialization in the form "sha256:<hex>".
+func Fingerprint(pipeline schema.Pipeline) (string, error) {
+	canonical, err := Canonical(pipeline)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(canonical)
+	return ALGORITHM + ":" + hex.EncodeToString(sum[:]), nil
+}
+
+func normalize(pipeline schema.Pipeline) (any, error) {
+	env := make(map[string]schema.Env, len(pipeline.Env))
+	for key, value := range pipeline.Env {
+		if value.Secret {
+			value.Value = ""
+		}
+		env[key] = value
+	}
+	pipeline.Env = env
+
+	data, err := json.Marshal(pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("error serializing pipeline - %s", err)
+	}
+	var tree any
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return nil, fmt.Errorf("error serializing pipeline - %s", err)
+	}
+
+	return prune(tree), nil
+}
+
+// prune removes all object members with empty values.
+// List elements are kept, because their positions are significant.
+func prune(value any) any {
+	switch value := value.(type) {
+	case map[string]any:
+		for key, member := range value {
+			member = prune(member)
+			if isEmpty(member) {
+				delete(value, key)
+			} else {
+				value[key] = member
+			}
+		}
+		return value
+
+	case []any:
+		for i, element := range value {
+			value[i] = prune(element)
+		}
+		return value
+
+	default:
+		return value
+	}
+}
+
+func isEmpty(value any) bool {
+	switch value := value.(type) {
+	case nil:
+		return true
+	case string:
+		return value == ""
+	case bool:
+		return !value
+	case float64:
+		return value == 0
+	case map[string]any:
+		return len(value) == 0
+	case []any:
+		return len(value) == 0
+	default:
+		return false
+	}
+}
+
+func sortedKeys(m map[string]any) []string {
+	return slices.Sorted(maps.Keys(m))
+}
@@ -0,0 +1,196 @@
+package interpolation
+
+import (
+	"fmt"
+	"strings"
+)
+
+const SOURCE_ENV = "env"
+const SOURCE_VAR = "var"
+const SOURCE_OUTPUT = "output"
+const SOURCE_FACT = "fact"
+
+// Sources which can be referenced in params.
+var Sources = []string{SOURCE_ENV, SOURCE_VAR, SOURCE_OUTPUT}
+
+// Segment is either a literal or a reference to a value of a source.
+type Segment struct {
+	Literal string
+
+	Source    string
+	Key       string
+	Replace   []string
+	Transform []string
+}
+
+func (s Segment) IsReference() bool {
+	return s.Source != ""
+}
+
+// Parse splits a string into literals and references.
+//
+// A reference has the form "${<source>:<key>}" where source is one of Sources,
+// optionally followed by regexp substitutions like "${env:IMAGE|/:.*//}" and transforms like "${env:BRANCH|lower}".
+// Transforms start with a letter, so letters cannot be used as separators of substitutions.
+// Substitutions are always applied before transforms.
+// "$${" results in a literal "${", any other "${" which does not start with a known source is kept as is,
+// so shell variables like "${HOME}" do not need to be escaped.
+func Parse(s string) ([]Segment, error) {
+	return ParseSources(s, Sources)
+}
+
+// ParseSources is like Parse, but only recognizes references to the given sources.
+func ParseSources(s string, sources []string) ([]Segment, error) {
+	length := len(s)
+	result := make([]Segment, 0, 1)
+	literal := strings.Builder{}
+
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			literal.WriteString(s)
+			break
+		}
+
+		if i > 0 && s[i-1] == '$' {
+			literal.WriteString(s[:i-1] + "${")
+			s = s[i+2:]
+			continue
+		}
+
+		source, ok := referenceSource(s[i+2:], sources)
+		if !ok {
+			literal.WriteString(s[:i+2])
+			s = s[i+2:]
+			continue
+		}
+
+		literal.WriteString(s[:i])
+		if literal.Len() > 0 {
+			result = append(result, Segment{Literal: literal.String()})
+			literal.Reset()
+		}
+
+		segment, rest, err := parseReference(s[i+2+len(source)+1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid reference at position %v - %s", length-len(s)+i, err)
+		}
+		segment.Source = source
+		result = append(result, segment)
+		s = rest
+	}
+
+	if literal.Len() > 0 || len(result) == 0 {
+		result = append(result, Segment{Literal: literal.String()})
+	}
+
+	return result, nil
+}
+
+// References returns all references contained in s.
+func References(s string) ([]Segment, error) {
+	segments, err := Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Segment, 0, len(segments))
+	for _, segment := range segments {
+		if segment.IsReference() {
+			result = append(result, segment)
+		}
+	}
+	return result, nil
+}
+
+func referenceSource(s string, sources []string) (string, bool) {
+	for _, source := range sources {
+		if strings.HasPrefix(s, source+":") {
+			return source, true
+		}
+	}
+	return "", false
+}
+
+// parseReference parses "<key>[|<expression>...]}" and returns the remaining string.
+func parseReference(s string) (result Segment, rest string, err error) {
+	end := strings.IndexAny(s, "|}")
+	if end < 0 {
+		err = fmt.Errorf("missing closing '}'")
+		return
+	}
+	result.Key = s[:end]
+	if result.Key == "" {
+		err = fmt.Errorf("key must not be empty")
+		return
+	}
+	s = s[end:]
+
+	for s[0] == '|' {
+		s = s[1:]
+		if s != "" && isLetter(s[0]) {
+			end := strings.IndexAny(s, "|}")
+			if end < 0 {
+				err = fmt.Errorf("missing closing '}'")
+				return
+			}
+			result.Transform = append(result.Transform, s[:end])
+			s = s[end:]
+			continue
+		}
+
+		var expression string
+		expression, s, err = parseExpression(s)
+		if err != nil {
+			return
+		}
+		result.Replace = append(result.Replace, expression)
+		if s == "" {
+			err = fmt.Errorf("missing closing '}'")
+			return
+		}
+	}
+
+	if s[0] != '}' {
+		err = fmt.Errorf("unexpected character '%c'", s[0])
+		return
+	}
+	return result, s[1:], nil
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// parseExpression reads a regexp substitution "/regex/substitution/" where '/' may be any character except '\'.
+func parseExpression(s string) (expression string, rest string, err error) {
+	runes := []rune(s)
+	if len(runes) == 0 {
+		err = fmt.Errorf("missing expression")
+		return
+	}
+	separator := runes[0]
+	if separator == '\\' || separator == '}' {
+		err = fmt.Errorf("invalid expression separator '%c'", separator)
+		return
+	}
+
+	separators := 1
+	escapes := 0
+	for i := 1; i < len(runes); i++ {
+		switch {
+		case runes[i] == separator && escapes%2 == 0:
+			separators += 1
+			if separators == 3 {
+				return string(runes[:i+1]), string(runes[i+1:]), nil
+			}
+			escapes = 0
+		case runes[i] == '\\':
+			escapes += 1
+		default:
+			escapes = 0
+		}
+	}
+
+	err = fmt.Errorf("unterminated expression")
+	return
+}
@@ -0,0 +1,290 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/reeveci/reeve-lib/schema"
+	"github.com/reeveci/reeve-lib/transforms"
+)
+
+const SCHEMA_DRAFT = "https://json-schema.org/draft/2020-12/schema"
+
+// Generate returns a JSON Schema document describing schema.PipelineDefinition.
+func Generate() map[string]any {
+	g := &generator{defs: make(map[string]any)}
+
+	root := g.structSchema(reflect.TypeFor[schema.PipelineDefinition]())
+	root["$schema"] = SCHEMA_DRAFT
+	root["title"] = "Reeve pipeline definition"
+	root["$defs"] = g.defs
+
+	return root
+}
+
+func Marshal() ([]byte, error) {
+	return json.MarshalIndent(Generate(), "", "  ")
+}
+
+type generator struct {
+	defs map[string]any
+}
+
+func (g *generator) schemaFor(t reflect.Type) map[string]any {
+	if special := g.special(t); special != nil {
+		return g.ref(t.Name(), special)
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.schemaFor(t.Elem())
+
+	case reflect.String:
+		return map[string]any{"type": "string"}
+
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer", "minimum": 0}
+
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": g.schemaFor(t.Elem())}
+
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schemaFor(t.Elem())}
+
+	case reflect.Struct:
+		return g.ref(t.Name(), func(g *generator) map[string]any { return g.structSchema(t) })
+
+	default:
+		return map[string]any{}
+	}
+}
+
+func (g *generator) ref(name string, build func(g *generator) map[string]any) map[string]any {
+	if _, ok := g.defs[name]; !ok {
+		// reserve the name first so that recursive types terminate
+		g.defs[name] = nil
+		def := build(g)
+		if description, ok := descriptions[name]; ok {
+			def["description"] = description
+		}
+		g.defs[name] = def
+	}
+	return map[string]any{"$ref": "#/$defs/" + name}
+}
+
+func (g *generator) structSchema(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+	g.addProperties(t, t.Name(), properties)
+
+	result := map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if description, ok := descriptions[t.Name()]; ok {
+		result["description"] = description
+	}
+	return result
+}
+
+func (g *generator) addProperties(t reflect.Type, typeName string, properties map[string]any) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			g.addProperties(field.Type, field.Type.Name(), properties)
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		property := g.schemaFor(field.Type)
+		key := typeName + "." + name
+		if description, ok := descriptions[key]; ok {
+			property = withAnnotation(property, "description", description)
+		}
+		if deprecated[key] {
+			property = withAnnotation(property, "deprecated", true)
+		}
+		properties[name] = property
+	}
+}
+
+func withAnnotation(s map[string]any, key string, value any) map[string]any {
+	result := make(map[string]any, len(s)+1)
+	for k, v := range s {
+		result[k] = v
+	}
+	result[key] = value
+	return result
+}
+
+func (g *generator) special(t reflect.Type) func(g *generator) map[string]any {
+	switch t {
+	case reflect.TypeFor[schema.Param]():
+		return func(g *generator) map[string]any {
+			return map[string]any{
+				"oneOf": []any{
+					g.schemaFor(reflect.TypeFor[schema.LiteralParam]()),
+					g.schemaFor(reflect.TypeFor[schema.EnvParam]()),
+					g.schemaFor(reflect.TypeFor[schema.VarParam]()),
+					g.schemaFor(reflect.TypeFor[schema.OutputParam]()),
+				},
+			}
+		}
+
+	case reflect.TypeFor[schema.Command]():
+		return func(g *generator) map[string]any {
+			return map[string]any{
+				"oneOf": []any{
+					g.schemaFor(reflect.TypeFor[schema.LiteralCommand]()),
+					g.schemaFor(reflect.TypeFor[schema.Param]()),
+				},
+			}
+		}
+
+	case reflect.TypeFor[schema.LiteralParam]():
+		return func(g *generator) map[string]any {
+			return map[string]any{"type": []string{"string", "number", "boolean"}}
+		}
+
+	case reflect.TypeFor[schema.LiteralCommand]():
+		return func(g *generator) map[string]any {
+			return map[string]any{"type": "array", "items": map[string]any{"type": "string"}}
+		}
+
+	case reflect.TypeFor[schema.EnvParam]():
+		return func(g *generator) map[string]any {
+			result := g.structSchema(reflect.TypeFor[schema.EnvParam]())
+			result["required"] = []string{"env"}
+			return result
+		}
+
+	case reflect.TypeFor[schema.VarParam]():
+		return func(g *generator) map[string]any {
+			result := g.structSchema(reflect.TypeFor[schema.VarParam]())
+			result["required"] = []string{"var"}
+			return result
+		}
+
+	case reflect.TypeFor[schema.OutputParam]():
+		return func(g *generator) map[string]any {
+			result := g.structSchema(reflect.TypeFor[schema.OutputParam]())
+			result["required"] = []string{"step", "output"}
+			return result
+		}
+
+	default:
+		return nil
+	}
+}
+
+var deprecated = map[string]bool{
+	"RunConfig.directory": true,
+}
+
+var transformDescription = "Built-in transforms applied in order after the replacements, in the form \"name\" or \"name:argument\". Available: " + strings.Join(transforms.Names(), ", ") + "."
+var replaceDescription = `Regexp substitutions applied to the value in order, in the form "/regex/substitution/" where '/' may be replaced by any unicode character except '\'.`
+
+var descriptions = map[string]string{
+	"PipelineDefinition":             "A pipeline consisting of steps which are run by a worker.",
+	"PipelineDefinition.apiVersion":  `Version of the pipeline schema, defaults to "` + schema.API_VERSION_V1 + `". Older versions are migrated to "` + schema.CURRENT_API_VERSION + `" by the host, which reports deprecation warnings.`,
+	"PipelineDefinition.name":        "Unique name of the pipeline.",
+	"PipelineDefinition.headline":    "Short headline shown in notifications.",
+	"PipelineDefinition.description": "Longer description of the pipeline.",
+	"PipelineDefinition.extends":     "Names of the templates this pipeline inherits steps, conditions and setup from. Later templates override earlier ones, the pipeline overrides all templates. Fields which are set override inherited values, even if they are empty.",
+	"PipelineDefinition.when":        `Conditions which must all be met for the pipeline to run. Keys are fact names, "env <KEY>" or "var <KEY>".`,
+	"PipelineDefinition.selector":    `Label selectors a worker has to meet to receive the pipeline, e.g. "os=linux", "arch in (amd64,arm64)", "!gpu" or "docker".`,
+	"PipelineDefinition.concurrency": "Prevents pipelines of the same group from running at the same time.",
+	"PipelineDefinition.steps":       "Steps of the pipeline.",
+
+	"Step":               "A single task run by the worker.",
+	"Concurrency.group":  `Name of the concurrency group, may reference env and facts like "deploy-${fact:branch}".`,
+	"Concurrency.policy": `What to do if another pipeline of the group is active: "queue" (default) waits for it, "cancel-in-progress" cancels it and "skip-if-running" skips the new pipeline if the other one is already running.`,
+
+	"Step.name":          "Name of the step.",
+	"Step.stage":         `Stage the step belongs to. Steps without a stage are part of the "` + schema.DEFAULT_STAGE + `" stage.`,
+	"Step.needs":         "Names of the steps this step depends on. If set, the step no longer waits for all steps of the preceding stages.",
+	"Step.when":          `Conditions which must all be met for the step to run. Keys are fact names, "env <KEY>" or "var <KEY>".`,
+	"Step.selector":      "Label selectors the worker has to meet. Since all steps run on the same worker, they apply to the whole pipeline.",
+	"Step.ignoreFailure": "Continue the pipeline even if this step fails.",
+
+	"Step.outputs": `Names of the outputs the step writes in the form "name=value". Later steps can reference them with output params.`,
+	"Step.matrix":  "Run the step once for every combination of the matrix. Outputs of matrix steps cannot be referenced by other steps.",
+	"Step.vars":    "Vars which are only available to this step. Matrix expansion adds the combination as \"" + schema.MATRIX_VAR_PREFIX + "<axis>\".",
+
+	"Step.timeout": `Maximum duration of a single attempt, e.g. "10m".`,
+	"Step.retry":   "Retry the step if it fails.",
+
+	"RetryPolicy":               "Defines when and how often a failed step is retried.",
+	"RetryPolicy.attempts":      "Maximum number of attempts including the first one.",
+	"RetryPolicy.backoff":       `Delay before the first retry, e.g. "10s".`,
+	"RetryPolicy.backoffFactor": "Factor the delay is multiplied with after every retry, defaults to 1.",
+	"RetryPolicy.maxBackoff":    "Upper limit for the delay.",
+	"RetryPolicy.exitCodes":     "Only retry if the task exits with one of these codes. If empty, any failure including timeouts is retried.",
+
+	"Matrix":         "Combinations are the cartesian product of all axes, without those matching an exclude entry, followed by all include entries.",
+	"Matrix.axes":    "Values for each axis.",
+	"Matrix.include": "Additional combinations.",
+	"Matrix.exclude": "Combinations to skip. An entry matches a combination if all of its keys have the same value.",
+
+	"RunConfig.task":      "Task to run, usually a container image.",
+	"RunConfig.command":   "Command to run, either a list of arguments or a param which is split like a shell command.",
+	"RunConfig.input":     "Data passed to the standard input of the task.",
+	"RunConfig.mounts":    "Mounts made available to the task.",
+	"RunConfig.directory": "Deprecated: Use mounts instead.",
+	"RunConfig.user":      "User the task is run as.",
+	"RunConfig.params":    "Named params passed to the task.",
+
+	"Condition":             "A condition on a fact, env or var. If no rule is set or the value is empty, the condition is met.",
+	"Condition.include":     "The value must match one of these literals (or another include rule).",
+	"Condition.exclude":     "The value must not match any of these literals.",
+	"Condition.include env": "The value must match the value of one of these env keys (or another include rule).",
+	"Condition.exclude env": "The value must not match the value of any of these env keys.",
+	"Condition.include var": "The value must match the value of one of these vars (or another include rule).",
+	"Condition.exclude var": "The value must not match the value of any of these vars.",
+	"Condition.match":       "The value must match one of these regular expressions (or another include rule).",
+	"Condition.mismatch":    "The value must not match any of these regular expressions.",
+
+	"Param":                 "A literal string or a reference to an env, var or step output.",
+	"Command":               "A list of arguments or a param which is split like a shell command.",
+	"LiteralParam":          `A literal value. References like "${env:KEY}", "${var:KEY}" or "${output:STEP.OUTPUT}" are replaced by their values and may be followed by substitutions and transforms, e.g. "${env:KEY|/regex/substitution/|lower}". Use "$${" for a literal "${".`,
+	"LiteralCommand":        "A list of command arguments. References are interpolated into every argument like in literal params.",
+	"EnvParam":              "A reference to an env value.",
+	"EnvParam.env":          "Env key.",
+	"EnvParam.transform":    transformDescription,
+	"EnvParam.replace":      replaceDescription,
+	"EnvParam.fallback":     "Params tried in order if the env is not available. The replace expressions of this param are not applied to them.",
+	"EnvParam.default":      "Literal used as is if neither the env nor any fallback is available.",
+	"VarParam":              "A reference to a var.",
+	"VarParam.var":          "Var key.",
+	"VarParam.fallback":     "Params tried in order if the var is not available. The replace expressions of this param are not applied to them.",
+	"VarParam.default":      "Literal used as is if neither the var nor any fallback is available.",
+	"OutputParam":           "A reference to an output of a step this step depends on.",
+	"OutputParam.step":      "Name of the step.",
+	"OutputParam.output":    "Name of the output.",
+	"OutputParam.transform": transformDescription,
+	"OutputParam.replace":   replaceDescription,
+	"VarParam.transform":    transformDescription,
+	"VarParam.replace":      replaceDescription,
+}
@@ -0,0 +1,213 @@
+package labels
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+type Operator string
+
+const (
+	OPERATOR_EQUALS     Operator = "="
+	OPERATOR_NOT_EQUALS Operator = "!="
+	OPERATOR_IN         Operator = "in"
+	OPERATOR_NOT_IN     Operator = "notin"
+	OPERATOR_EXISTS     Operator = "exists"
+	OPERATOR_NOT_EXISTS Operator = "!"
+)
+
+var keyPattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._/-]*[A-Za-z0-9])?$`)
+
+// Requirement is a single condition on a label set.
+// Negated requirements (!=, notin, !key) are also met if the label is missing.
+type Requirement struct {
+	Key      string
+	Operator Operator
+	Values   []string
+}
+
+func (r Requirement) Matches(labels map[string]string) bool {
+	value, ok := labels[r.Key]
+	switch r.Operator {
+	case OPERATOR_EQUALS:
+		return ok && value == r.Values[0]
+	case OPERATOR_NOT_EQUALS:
+		return !ok || value != r.Values[0]
+	case OPERATOR_IN:
+		return ok && slices.Contains(r.Values, value)
+	case OPERATOR_NOT_IN:
+		return !ok || !slices.Contains(r.Values, value)
+	case OPERATOR_EXISTS:
+		return ok
+	case OPERATOR_NOT_EXISTS:
+		return !ok
+	default:
+		return false
+	}
+}
+
+func (r Requirement) String() string {
+	switch r.Operator {
+	case OPERATOR_EQUALS, OPERATOR_NOT_EQUALS:
+		return r.Key + string(r.Operator) + r.Values[0]
+	case OPERATOR_IN, OPERATOR_NOT_IN:
+		return fmt.Sprintf("%s %s (%s)", r.Key, r.Operator, strings.Join(r.Values, ","))
+	case OPERATOR_NOT_EXISTS:
+		return "!" + r.Key
+	default:
+		return r.Key
+	}
+}
+
+// Selector is met if all of its requirements are met.
+type Selector []Requirement
+
+func (s Selector) Matches(labels map[string]string) bool {
+	for _, requirement := range s {
+		if !requirement.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s Selector) String() string {
+	parts := make([]string, len(s))
+	for i, requirement := range s {
+		parts[i] = requirement.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// Parse parses selector expressions. Each expression may contain multiple comma separated requirements:
+//
+//	key=value, key==value, key!=value, key in (a,b), key notin (a,b), key, !key
+func Parse(expressions ...string) (Selector, error) {
+	result := make(Selector, 0, len(expressions))
+	for _, expression := range expressions {
+		parts, err := splitRequirements(expression)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, part := range parts {
+			requirement, err := parseRequirement(part)
+			if err != nil {
+				return nil, fmt.Errorf(`invalid label selector "%s" - %s`, expression, err)
+			}
+			result = append(result, requirement)
+		}
+	}
+	return result, nil
+}
+
+func Validate(expression string) error {
+	_, err := Parse(expression)
+	return err
+}
+
+// Match reports whether the labels meet all selector expressions.
+func Match(labels map[string]string, expressions ...string) (bool, error) {
+	selector, err := Parse(expressions...)
+	if err != nil {
+		return false, err
+	}
+	return selector.Matches(labels), nil
+}
+
+func ValidateKey(key string) error {
+	if !keyPattern.MatchString(key) {
+		return fmt.Errorf(`invalid label key "%s"`, key)
+	}
+	return nil
+}
+
+func splitRequirements(expression string) ([]string, error) {
+	var result []string
+	depth := 0
+	start := 0
+	for i, c := range expression {
+		switch c {
+		case '(':
+			depth++
+			if depth > 1 {
+				return nil, fmt.Errorf(`invalid label selector "%s" - nested parentheses`, expression)
+			}
+		case ')':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf(`invalid label selector "%s" - unexpected ")"`, expression)
+			}
+		case ',':
+			if depth == 0 {
+				result = append(result, expression[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf(`invalid label selector "%s" - missing ")"`, expression)
+	}
+	return append(result, expression[start:]), nil
+}
+
+func parseRequirement(value string) (Requirement, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return Requirement{}, fmt.Errorf("empty requirement")
+	}
+
+	if key, ok := strings.CutPrefix(value, "!"); ok && !strings.Contains(key, "=") {
+		key = strings.TrimSpace(key)
+		return Requirement{Key: key, Operator: OPERATOR_NOT_EXISTS}, ValidateKey(key)
+	}
+
+	if key, rest, ok := strings.Cut(value, "!="); ok {
+		return parseEquality(key, OPERATOR_NOT_EQUALS, rest)
+	}
+	if key, rest, ok := strings.Cut(value, "=="); ok {
+		return parseEquality(key, OPERATOR_EQUALS, rest)
+	}
+	if key, rest, ok := strings.Cut(value, "="); ok {
+		return parseEquality(key, OPERATOR_EQUALS, rest)
+	}
+
+	if fields := strings.Fields(value); len(fields) >= 2 && (fields[1] == string(OPERATOR_IN) || fields[1] == string(OPERATOR_NOT_IN)) {
+		key := fields[0]
+		if err := ValidateKey(key); err != nil {
+			return Requirement{}, err
+		}
+
+		rest := strings.TrimSpace(value[len(key):])
+		rest = strings.TrimSpace(rest[len(fields[1]):])
+		list, ok := strings.CutPrefix(rest, "(")
+		if !ok {
+			return Requirement{}, fmt.Errorf(`expected "(" after "%s"`, fields[1])
+		}
+		list, ok = strings.CutSuffix(list, ")")
+		if !ok {
+			return Requirement{}, fmt.Errorf(`expected ")" at the end of the value list`)
+		}
+
+		values := strings.Split(list, ",")
+		for i := range values {
+			values[i] = strings.TrimSpace(values[i])
+			if values[i] == "" {
+				return Requirement{}, fmt.Errorf("empty value in value list")
+			}
+		}
+		return Requirement{Key: key, Operator: Operator(fields[1]), Values: values}, nil
+	}
+
+	return Requirement{Key: value, Operator: OPERATOR_EXISTS}, ValidateKey(value)
+}
+
+func parseEquality(key string, operator Operator, value string) (Requirement, error) {
+	key = strings.TrimSpace(key)
+	if err := ValidateKey(key); err != nil {
+		return Requirement{}, err
+	}
+	return Requirement{Key: key, Operator: operator, Values: []string{strings.TrimSpace(value)}}, nil
+}
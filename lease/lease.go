@@ -0,0 +1,213 @@
+package lease
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/reeveci/reeve-lib/schema"
+)
+
+type Lease struct {
+	Contract string
+	Activity string
+	Expires  time.Time
+	// The worker acknowledged the contract and is running the pipeline
+	Acknowledged bool
+	// Number of times the pipeline has been returned to the queue before this lease was granted
+	Requeues int
+}
+
+type Expired struct {
+	Lease
+	// STATUS_ENQUEUED if the pipeline should be returned to the queue, STATUS_CANCELLED if it was cancelled
+	// and STATUS_TIMEOUT if it was requeued too often
+	Status schema.Status
+}
+
+// Tracker keeps track of the contracts handed out to workers.
+// Contracts which are not renewed in time expire and are reported by Expire.
+type Tracker struct {
+	duration    time.Duration
+	maxRequeues int
+
+	leases   map[string]*Lease
+	requeues map[string]int
+	cancel   map[string]bool
+	lock     sync.Mutex
+
+	// Now returns the current time and may be replaced, e.g. for tests
+	Now func() time.Time
+}
+
+// NewTracker creates a tracker granting leases of the given duration.
+// Expired pipelines are returned to the queue at most maxRequeues times, afterwards they time out.
+func NewTracker(duration time.Duration, maxRequeues int) *Tracker {
+	return &Tracker{
+		duration:    duration,
+		maxRequeues: maxRequeues,
+		leases:      make(map[string]*Lease),
+		requeues:    make(map[string]int),
+		cancel:      make(map[string]bool),
+		Now:         time.Now,
+	}
+}
+
+// HeartbeatInterval returns the interval workers should send heartbeats in, leaving time for two retries.
+func (t *Tracker) HeartbeatInterval() time.Duration {
+	return t.duration / 3
+}
+
+// Grant creates a new contract for the activity.
+func (t *Tracker) Grant(activity string) (Lease, error) {
+	contract, err := newContract()
+	if err != nil {
+		return Lease{}, err
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	lease := &Lease{
+		Contract: contract,
+		Activity: activity,
+		Expires:  t.Now().Add(t.duration),
+		Requeues: t.requeues[activity],
+	}
+	t.leases[contract] = lease
+	return *lease, nil
+}
+
+// Ack marks the contract as acknowledged and renews it.
+func (t *Tracker) Ack(contract string) (Lease, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	lease, err := t.renew(contract)
+	if err != nil {
+		return Lease{}, err
+	}
+	lease.Acknowledged = true
+	return *lease, nil
+}
+
+// Heartbeat renews the contract and reports whether the pipeline was cancelled.
+func (t *Tracker) Heartbeat(contract string) (schema.WorkerHeartbeatResponse, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	lease, err := t.renew(contract)
+	if err != nil {
+		return schema.WorkerHeartbeatResponse{}, err
+	}
+	return schema.WorkerHeartbeatResponse{LeaseExpires: lease.Expires, Cancel: t.cancel[lease.Activity]}, nil
+}
+
+// Cancel requests the worker running the activity to stop with its next heartbeat.
+// Call Forget if the activity will never be granted a lease again.
+func (t *Tracker) Cancel(activity string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.cancel[activity] = true
+}
+
+// Release removes the contract once the pipeline finished.
+func (t *Tracker) Release(contract string) (Lease, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	lease, err := t.get(contract)
+	if err != nil {
+		return Lease{}, err
+	}
+	delete(t.leases, contract)
+	t.forget(lease.Activity)
+	return *lease, nil
+}
+
+// Forget removes all state of an activity, e.g. once its pipeline finished or was cancelled before a lease was granted.
+// Leases of the activity are revoked.
+func (t *Tracker) Forget(activity string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for contract, lease := range t.leases {
+		if lease.Activity == activity {
+			delete(t.leases, contract)
+		}
+	}
+	t.forget(activity)
+}
+
+func (t *Tracker) forget(activity string) {
+	delete(t.requeues, activity)
+	delete(t.cancel, activity)
+}
+
+// Expire removes all expired contracts and reports what should happen to their pipelines.
+func (t *Tracker) Expire() []Expired {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	now := t.Now()
+	result := make([]Expired, 0)
+	for contract, lease := range t.leases {
+		if now.Before(lease.Expires) {
+			continue
+		}
+		delete(t.leases, contract)
+
+		expired := Expired{Lease: *lease, Status: schema.STATUS_ENQUEUED}
+		switch {
+		case t.cancel[lease.Activity]:
+			expired.Status = schema.STATUS_CANCELLED
+			t.forget(lease.Activity)
+		case lease.Requeues >= t.maxRequeues:
+			expired.Status = schema.STATUS_TIMEOUT
+			t.forget(lease.Activity)
+		default:
+			t.requeues[lease.Activity] = lease.Requeues + 1
+		}
+		result = append(result, expired)
+	}
+	return result
+}
+
+// Get returns the lease of a contract which has not expired.
+func (t *Tracker) Get(contract string) (Lease, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	lease, err := t.get(contract)
+	if err != nil {
+		return Lease{}, err
+	}
+	return *lease, nil
+}
+
+func (t *Tracker) renew(contract string) (*Lease, error) {
+	lease, err := t.get(contract)
+	if err != nil {
+		return nil, err
+	}
+	lease.Expires = t.Now().Add(t.duration)
+	return lease, nil
+}
+
+func (t *Tracker) get(contract string) (*Lease, error) {
+	lease, ok := t.leases[contract]
+	if !ok || !t.Now().Before(lease.Expires) {
+		return nil, schema.ERROR_STALE_CONTRACT
+	}
+	return lease, nil
+}
+
+func newContract() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
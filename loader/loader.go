@@ -0,0 +1,274 @@
+package loader
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/reeveci/reeve-lib/schema"
+	"gopkg.in/yaml.v3"
+)
+
+// Patterns of the files which LoadDir loads by default.
+// Patterns without "/" match the file name, others match the last path elements, e.g. ".reeve/*.yaml".
+var Patterns = []string{
+	".reeve.yaml", ".reeve.yml", ".reeve.json",
+	".reeve/*.yaml", ".reeve/*.yml", ".reeve/*.json",
+}
+
+type Location struct {
+	File   string
+	Line   int
+	Column int
+}
+
+func (l Location) String() string {
+	if l.Line == 0 {
+		return l.File
+	}
+	return fmt.Sprintf("%s:%v:%v", l.File, l.Line, l.Column)
+}
+
+// Document is a single pipeline definition together with the location of its elements.
+type Document struct {
+	File string
+	// Index of the document within a multi-document file
+	Index      int
+	Definition schema.PipelineDefinition
+	// Locations are keyed by the same paths as schema.ValidationError, the document itself is stored as ""
+	Locations map[string]Location
+}
+
+// Locate returns the location of the element at path or of its closest parent which has a location.
+func (d Document) Locate(path string) Location {
+	for {
+		if location, ok := d.Locations[path]; ok {
+			return location
+		}
+		if path == "" {
+			return Location{File: d.File}
+		}
+		path = parentPath(path)
+	}
+}
+
+type LocatedError struct {
+	Location Location
+	schema.ValidationError
+}
+
+func (e LocatedError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Location, e.ValidationError.Error())
+}
+
+// Validate validates the definition and attaches source locations to all errors.
+func (d Document) Validate() []LocatedError {
+	errs := d.Definition.Validate()
+	result := make([]LocatedError, len(errs))
+	for i, err := range errs {
+		result[i] = LocatedError{Location: d.Locate(err.Path), ValidationError: err}
+	}
+	return result
+}
+
+// Definitions returns the definitions of all documents.
+func Definitions(documents []Document) []schema.PipelineDefinition {
+	result := make([]schema.PipelineDefinition, len(documents))
+	for i, document := range documents {
+		result[i] = document.Definition
+	}
+	return result
+}
+
+// Load loads files and directory trees. Directories are searched recursively for files matching Patterns,
+// which are loaded in lexical order. Files given explicitly are loaded regardless of their extension.
+func Load(paths ...string) ([]Document, error) {
+	result := make([]Document, 0)
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("error loading pipelines - %s", err)
+		}
+
+		var documents []Document
+		if info.IsDir() {
+			documents, err = LoadDir(path)
+		} else {
+			documents, err = LoadFile(path)
+		}
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, documents...)
+	}
+	return result, nil
+}
+
+// LoadDir loads all files in the directory tree which match one of patterns in lexical order, skipping ".git" directories.
+// Patterns defaults to Patterns, see there for the syntax.
+func LoadDir(root string, patterns ...string) ([]Document, error) {
+	if len(patterns) == 0 {
+		patterns = Patterns
+	}
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf(`invalid pattern "%s" - %s`, pattern, err)
+		}
+	}
+
+	result := make([]Document, 0)
+	err := filepath.WalkDir(root, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if entry.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		relative, err := filepath.Rel(root, file)
+		if err != nil {
+			return err
+		}
+		if !slices.ContainsFunc(patterns, func(pattern string) bool { return matchPattern(pattern, relative) }) {
+			return nil
+		}
+
+		documents, err := LoadFile(file)
+		if err != nil {
+			return err
+		}
+		result = append(result, documents...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error loading pipelines from %s - %s", root, err)
+	}
+	return result, nil
+}
+
+// matchPattern matches the pattern against as many trailing elements of file as the pattern has.
+func matchPattern(pattern, file string) bool {
+	elements := strings.Split(filepath.ToSlash(file), "/")
+	count := strings.Count(pattern, "/") + 1
+	if count > len(elements) {
+		return false
+	}
+	matched, _ := path.Match(pattern, strings.Join(elements[len(elements)-count:], "/"))
+	return matched
+}
+
+func LoadFile(path string) ([]Document, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading pipelines - %s", err)
+	}
+	return Parse(path, data)
+}
+
+// Parse decodes all YAML or JSON documents in data, file is only used for locations.
+// Unknown keys are rejected and empty documents are skipped.
+func Parse(file string, data []byte) ([]Document, error) {
+	result := make([]Document, 0, 1)
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	// decodes the same documents in lockstep, since only the decoder can reject unknown keys
+	strictDecoder := yaml.NewDecoder(bytes.NewReader(data))
+	strictDecoder.KnownFields(true)
+
+	for index := 0; ; index++ {
+		var node yaml.Node
+		err := decoder.Decode(&node)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s - %s", file, err)
+		}
+
+		var definition schema.PipelineDefinition
+		if err := strictDecoder.Decode(&definition); err != nil {
+			return nil, fmt.Errorf("error parsing %s - %s", file, err)
+		}
+
+		root := &node
+		if root.Kind == yaml.DocumentNode {
+			if len(root.Content) == 0 {
+				continue
+			}
+			root = root.Content[0]
+		}
+		if root.Kind == yaml.ScalarNode && root.Tag == "!!null" {
+			continue
+		}
+
+		document := Document{File: file, Index: index, Definition: definition, Locations: make(map[string]Location)}
+		document.Locations[""] = Location{File: file, Line: root.Line, Column: root.Column}
+		collectLocations(file, "", root, document.Locations)
+
+		result = append(result, document)
+	}
+
+	return result, nil
+}
+
+func collectLocations(file, path string, node *yaml.Node, locations map[string]Location) {
+	if node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		// merged keys first, so explicit keys take precedence
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Tag == "!!merge" {
+				collectLocations(file, path, node.Content[i+1], locations)
+			}
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Tag == "!!merge" {
+				continue
+			}
+
+			childPath := schema.KeyPath(path, key.Value)
+			locations[childPath] = Location{File: file, Line: key.Line, Column: key.Column}
+			collectLocations(file, childPath, value, locations)
+		}
+
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			childPath := schema.IndexPath(path, i)
+			locations[childPath] = Location{File: file, Line: item.Line, Column: item.Column}
+			collectLocations(file, childPath, item, locations)
+		}
+	}
+}
+
+// parentPath removes the last element from a path, respecting quoted keys.
+func parentPath(path string) string {
+	last := 0
+	quoted := false
+	escaped := false
+	for i, c := range path {
+		switch {
+		case escaped:
+			escaped = false
+		case quoted && c == '\\':
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case !quoted && (c == '.' || c == '['):
+			last = i
+		}
+	}
+	return path[:last]
+}
@@ -0,0 +1,122 @@
+package messages
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+
+	"github.com/reeveci/reeve-lib/schema"
+)
+
+// Message option containing the content type of the data
+const OPTION_CONTENT_TYPE = "content-type"
+
+// Message option containing the name of the schema of the data, used for routing
+const OPTION_SCHEMA = "schema"
+
+const CONTENT_TYPE_JSON = "application/json"
+const CONTENT_TYPE_GOB = "application/x-gob"
+const CONTENT_TYPE_TEXT = "text/plain"
+
+// New encodes value with the given content type.
+// Text values must be strings or byte slices.
+func New(target, schemaName, contentType string, value any) (schema.Message, error) {
+	data, err := Encode(contentType, value)
+	if err != nil {
+		return schema.Message{}, fmt.Errorf(`error encoding message with schema "%s" - %s`, schemaName, err)
+	}
+
+	return schema.Message{
+		Target: target,
+		Options: map[string]string{
+			OPTION_CONTENT_TYPE: contentType,
+			OPTION_SCHEMA:       schemaName,
+		},
+		Data: data,
+	}, nil
+}
+
+func NewJSON(target, schemaName string, value any) (schema.Message, error) {
+	return New(target, schemaName, CONTENT_TYPE_JSON, value)
+}
+
+func NewGob(target, schemaName string, value any) (schema.Message, error) {
+	return New(target, schemaName, CONTENT_TYPE_GOB, value)
+}
+
+func NewText(target, schemaName, text string) schema.Message {
+	message, _ := New(target, schemaName, CONTENT_TYPE_TEXT, text)
+	return message
+}
+
+func ContentType(message schema.Message) string {
+	return message.Options[OPTION_CONTENT_TYPE]
+}
+
+func SchemaName(message schema.Message) string {
+	return message.Options[OPTION_SCHEMA]
+}
+
+func Encode(contentType string, value any) ([]byte, error) {
+	switch contentType {
+	case CONTENT_TYPE_JSON:
+		return json.Marshal(value)
+
+	case CONTENT_TYPE_GOB:
+		var buffer bytes.Buffer
+		if err := gob.NewEncoder(&buffer).Encode(value); err != nil {
+			return nil, err
+		}
+		return buffer.Bytes(), nil
+
+	case CONTENT_TYPE_TEXT:
+		switch value := value.(type) {
+		case string:
+			return []byte(value), nil
+		case []byte:
+			return value, nil
+		default:
+			return nil, fmt.Errorf("text content requires a string, got %T", value)
+		}
+
+	default:
+		return nil, fmt.Errorf(`unsupported content type "%s"`, contentType)
+	}
+}
+
+// Decode decodes the message data into target, which must be a pointer.
+// Messages without content type are treated as text if target is a *string or *[]byte and as JSON otherwise.
+func Decode(message schema.Message, target any) error {
+	contentType := ContentType(message)
+	if contentType == "" {
+		switch target.(type) {
+		case *string, *[]byte:
+			contentType = CONTENT_TYPE_TEXT
+		default:
+			contentType = CONTENT_TYPE_JSON
+		}
+	}
+
+	switch contentType {
+	case CONTENT_TYPE_JSON:
+		return json.Unmarshal(message.Data, target)
+
+	case CONTENT_TYPE_GOB:
+		return gob.NewDecoder(bytes.NewReader(message.Data)).Decode(target)
+
+	case CONTENT_TYPE_TEXT:
+		switch target := target.(type) {
+		case *string:
+			*target = string(message.Data)
+		case *[]byte:
+			*target = bytes.Clone(message.Data)
+		default:
+			return fmt.Errorf("text content requires a *string or *[]byte target, got %T", target)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf(`unsupported content type "%s"`, contentType)
+	}
+}
@@ -0,0 +1,62 @@
+package messages
+
+import (
+	"fmt"
+
+	"github.com/reeveci/reeve-lib/schema"
+)
+
+type DecodeError struct {
+	Source      string
+	Schema      string
+	ContentType string
+	Err         error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf(`error decoding message with schema "%s" and content type "%s" from "%s" - %s`, e.Schema, e.ContentType, e.Source, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// Router dispatches messages to the handler registered for their schema name.
+// Its Message method can be used to implement plugin.Plugin.
+type Router struct {
+	handlers map[string]func(source string, message schema.Message) error
+
+	// Fallback handles messages without a registered handler, they are ignored if nil
+	Fallback func(source string, message schema.Message) error
+}
+
+func NewRouter() *Router {
+	return &Router{handlers: make(map[string]func(source string, message schema.Message) error)}
+}
+
+// Handle registers a handler for messages with the given schema name, replacing any previous handler.
+// Message data is decoded into T before the handler is called.
+func Handle[T any](router *Router, schemaName string, handler func(source string, value T) error) {
+	router.handlers[schemaName] = func(source string, message schema.Message) error {
+		var value T
+		if err := Decode(message, &value); err != nil {
+			return &DecodeError{Source: source, Schema: schemaName, ContentType: ContentType(message), Err: err}
+		}
+		return handler(source, value)
+	}
+}
+
+// HandleRaw registers a handler receiving the message unchanged.
+func (r *Router) HandleRaw(schemaName string, handler func(source string, message schema.Message) error) {
+	r.handlers[schemaName] = handler
+}
+
+func (r *Router) Message(source string, message schema.Message) error {
+	if handler, ok := r.handlers[SchemaName(message)]; ok {
+		return handler(source, message)
+	}
+	if r.Fallback != nil {
+		return r.Fallback(source, message)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/reeveci/reeve-lib/schema"
+)
+
+// Warning reports a deprecated construct which was migrated, so pipeline authors can update their definitions.
+type Warning struct {
+	// Location of the deprecated construct in the original definition, e.g. "steps[2].directory"
+	Path    string
+	Message string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%s: %s", w.Path, w.Message)
+}
+
+// Migration upgrades a definition from one api version to the next.
+// Setup is nil when migrating definitions without setup.
+type Migration struct {
+	From, To string
+	Migrate  func(definition *schema.PipelineDefinition, setup *schema.Setup) []Warning
+}
+
+// Migrations are keyed by the version they upgrade from.
+var Migrations = map[string]Migration{
+	schema.API_VERSION_V1: {From: schema.API_VERSION_V1, To: schema.API_VERSION_V2, Migrate: directoryToMounts},
+}
+
+// Migrate upgrades the definition and its setup in place to schema.CURRENT_API_VERSION.
+// Definitions of unknown versions are left unchanged and result in an error.
+func Migrate(definition *schema.PipelineDefinition, setup *schema.Setup) ([]Warning, error) {
+	warnings := make([]Warning, 0)
+
+	version := definition.GetAPIVersion()
+	for version != schema.CURRENT_API_VERSION {
+		migration, ok := Migrations[version]
+		if !ok {
+			return nil, fmt.Errorf(`unsupported apiVersion "%s"`, version)
+		}
+		warnings = append(warnings, migration.Migrate(definition, setup)...)
+		version = migration.To
+	}
+
+	definition.APIVersion = version
+	return warnings, nil
+}
+
+func MigratePipeline(pipeline *schema.Pipeline) ([]Warning, error) {
+	return Migrate(&pipeline.PipelineDefinition, &pipeline.Setup)
+}
+
+func MigrateTemplate(template *schema.PipelineTemplate) ([]Warning, error) {
+	return Migrate(&template.PipelineDefinition, template.Setup)
+}
+
+func directoryToMounts(definition *schema.PipelineDefinition, setup *schema.Setup) []Warning {
+	warnings := make([]Warning, 0)
+	migrate := func(path string, config *schema.RunConfig) {
+		if config.Directory.IsZero() {
+			return
+		}
+		config.Mounts = append(config.Mounts, config.Directory)
+		config.Directory = schema.Param{}
+		warnings = append(warnings, Warning{
+			Path:    schema.KeyPath(path, "directory"),
+			Message: fmt.Sprintf(`directory is deprecated - it was moved to %s`, schema.IndexPath(schema.KeyPath(path, "mounts"), len(config.Mounts)-1)),
+		})
+	}
+
+	if setup != nil {
+		migrate("setup", &setup.RunConfig)
+	}
+	for i := range definition.Steps {
+		migrate(schema.IndexPath("steps", i), &definition.Steps[i].RunConfig)
+	}
+	return warnings
+}
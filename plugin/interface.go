@@ -35,7 +35,8 @@ type Plugin interface {
 
 var Handshake = goplugin.HandshakeConfig{
 	// This isn't required when using VersionedPlugins
-	ProtocolVersion:  1,
+	// Version 2 encodes params and commands as JSON within gob, plugins built against version 1 are rejected
+	ProtocolVersion:  2,
 	MagicCookieKey:   "REEVE_PLUGIN",
 	MagicCookieValue: "reeveci",
 }
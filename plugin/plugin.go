@@ -28,14 +28,6 @@ func Serve(config *PluginConfig) {
 }
 
 func RegisterSharedTypes() {
-	// params
-	gob.Register(schema.LiteralCommand(nil))
-	gob.Register(schema.LiteralParam(""))
-	gob.Register(schema.EnvParam{})
-	gob.Register(schema.VarParam{})
-	gob.Register(map[string]any{})
-	gob.Register([]any{})
-
 	// plugin arguments
 	gob.Register(map[string]string{})
 	gob.Register(schema.PipelineStatus{})
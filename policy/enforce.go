@@ -0,0 +1,122 @@
+package policy
+
+import (
+	"github.com/reeveci/reeve-lib/plugin"
+	"github.com/reeveci/reeve-lib/schema"
+)
+
+// Enforce wraps a plugin so that all host integration points respect the policy.
+// Denied operations are dropped and passed to report, which may be nil.
+func Enforce(impl plugin.Plugin, name string, policy *CompiledPolicy, report func(Denial)) plugin.Plugin {
+	if report == nil {
+		report = func(Denial) {}
+	}
+	return &enforcingPlugin{Plugin: impl, name: name, policy: policy, report: report}
+}
+
+type enforcingPlugin struct {
+	plugin.Plugin
+	name   string
+	policy *CompiledPolicy
+	report func(Denial)
+}
+
+func (p *enforcingPlugin) Register(settings map[string]string, api plugin.ReeveAPI) (plugin.Capabilities, error) {
+	return p.Plugin.Register(settings, &enforcingAPI{ReeveAPI: api, plugin: p})
+}
+
+func (p *enforcingPlugin) Resolve(env []string) (map[string]schema.Env, error) {
+	requested := make([]string, 0, len(env))
+	for _, key := range env {
+		if p.policy.MayProvideEnv(key) {
+			requested = append(requested, key)
+		}
+	}
+	if len(requested) == 0 {
+		return map[string]schema.Env{}, nil
+	}
+
+	result, err := p.Plugin.Resolve(requested)
+	if err != nil {
+		return nil, err
+	}
+
+	for key := range result {
+		if !p.policy.MayProvideEnv(key) {
+			delete(result, key)
+			p.report(Denial{Plugin: p.name, Operation: OPERATION_PROVIDE_ENV, Subject: key})
+		}
+	}
+	return result, nil
+}
+
+func (p *enforcingPlugin) Notify(status schema.PipelineStatus) error {
+	env := make(map[string]schema.Env, len(status.Pipeline.Env))
+	for key, value := range status.Pipeline.Env {
+		if p.policy.MayReceiveEnv(key) {
+			env[key] = value
+		}
+	}
+	status.Pipeline.Env = env
+
+	return p.Plugin.Notify(status)
+}
+
+type enforcingAPI struct {
+	plugin.ReeveAPI
+	plugin *enforcingPlugin
+}
+
+func (a *enforcingAPI) NotifyMessages(messages []schema.Message) error {
+	allowed := make([]schema.Message, 0, len(messages))
+	var denied DeniedError
+	for _, message := range messages {
+		if a.plugin.policy.MaySendMessage(message.Target) {
+			allowed = append(allowed, message)
+		} else {
+			denied = append(denied, Denial{Plugin: a.plugin.name, Operation: OPERATION_SEND_MESSAGE, Subject: message.Target})
+		}
+	}
+
+	return a.forward(denied, len(allowed) > 0, func() error {
+		return a.ReeveAPI.NotifyMessages(allowed)
+	})
+}
+
+func (a *enforcingAPI) NotifyTriggers(triggers []schema.Trigger) error {
+	allowed := make([]schema.Trigger, 0, len(triggers))
+	var denied DeniedError
+	for _, trigger := range triggers {
+		ok := true
+		for key := range trigger {
+			if !a.plugin.policy.MayEmitTrigger(key) {
+				ok = false
+				denied = append(denied, Denial{Plugin: a.plugin.name, Operation: OPERATION_EMIT_TRIGGER, Subject: key})
+			}
+		}
+		if ok {
+			allowed = append(allowed, trigger)
+		}
+	}
+
+	return a.forward(denied, len(allowed) > 0, func() error {
+		return a.ReeveAPI.NotifyTriggers(allowed)
+	})
+}
+
+func (a *enforcingAPI) forward(denied DeniedError, send bool, notify func() error) error {
+	for _, denial := range denied {
+		a.plugin.report(denial)
+	}
+
+	if send {
+		if err := notify(); err != nil {
+			return err
+		}
+	}
+
+	if len(denied) > 0 {
+		return denied
+	}
+	return nil
+}
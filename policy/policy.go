@@ -0,0 +1,123 @@
+package policy
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const OPERATION_PROVIDE_ENV = "provide env"
+const OPERATION_RECEIVE_ENV = "receive env"
+const OPERATION_SEND_MESSAGE = "send message"
+const OPERATION_EMIT_TRIGGER = "emit trigger"
+
+// Policy lists what a plugin is allowed to do.
+// Every entry is a pattern where '*' matches any sequence of characters, all other characters match literally.
+// An empty list denies the operation entirely.
+type Policy struct {
+	// env keys the plugin may provide values for when resolving
+	ProvideEnv []string `json:"provideEnv" yaml:"provideEnv"`
+	// env keys the plugin may see the values of in pipeline notifications
+	ReceiveEnv []string `json:"receiveEnv" yaml:"receiveEnv"`
+	// message targets the plugin may send messages to
+	MessageTargets []string `json:"messageTargets" yaml:"messageTargets"`
+	// trigger keys the plugin may emit
+	TriggerKeys []string `json:"triggerKeys" yaml:"triggerKeys"`
+}
+
+var AllowAll = Policy{
+	ProvideEnv:     []string{"*"},
+	ReceiveEnv:     []string{"*"},
+	MessageTargets: []string{"*"},
+	TriggerKeys:    []string{"*"},
+}
+
+func (p Policy) Compile() (*CompiledPolicy, error) {
+	var result CompiledPolicy
+	var err error
+
+	if result.provideEnv, err = compilePatterns(p.ProvideEnv); err != nil {
+		return nil, fmt.Errorf("invalid provideEnv - %s", err)
+	}
+	if result.receiveEnv, err = compilePatterns(p.ReceiveEnv); err != nil {
+		return nil, fmt.Errorf("invalid receiveEnv - %s", err)
+	}
+	if result.messageTargets, err = compilePatterns(p.MessageTargets); err != nil {
+		return nil, fmt.Errorf("invalid messageTargets - %s", err)
+	}
+	if result.triggerKeys, err = compilePatterns(p.TriggerKeys); err != nil {
+		return nil, fmt.Errorf("invalid triggerKeys - %s", err)
+	}
+
+	return &result, nil
+}
+
+type CompiledPolicy struct {
+	provideEnv, receiveEnv, messageTargets, triggerKeys []*regexp.Regexp
+}
+
+func (p *CompiledPolicy) MayProvideEnv(key string) bool {
+	return matchAny(p.provideEnv, key)
+}
+
+func (p *CompiledPolicy) MayReceiveEnv(key string) bool {
+	return matchAny(p.receiveEnv, key)
+}
+
+func (p *CompiledPolicy) MaySendMessage(target string) bool {
+	return matchAny(p.messageTargets, target)
+}
+
+func (p *CompiledPolicy) MayEmitTrigger(key string) bool {
+	return matchAny(p.triggerKeys, key)
+}
+
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	result := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		if pattern == "" {
+			return nil, fmt.Errorf("empty pattern")
+		}
+
+		parts := strings.Split(pattern, "*")
+		for i, part := range parts {
+			parts[i] = regexp.QuoteMeta(part)
+		}
+
+		regex, err := regexp.Compile("^" + strings.Join(parts, ".*") + "$")
+		if err != nil {
+			return nil, fmt.Errorf(`error compiling pattern "%s" - %s`, pattern, err)
+		}
+		result = append(result, regex)
+	}
+	return result, nil
+}
+
+func matchAny(patterns []*regexp.Regexp, value string) bool {
+	for _, pattern := range patterns {
+		if pattern.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+type Denial struct {
+	Plugin    string
+	Operation string
+	Subject   string
+}
+
+func (d Denial) String() string {
+	return fmt.Sprintf(`plugin "%s" is not allowed to %s "%s"`, d.Plugin, d.Operation, d.Subject)
+}
+
+type DeniedError []Denial
+
+func (err DeniedError) Error() string {
+	messages := make([]string, len(err))
+	for i, denial := range err {
+		messages[i] = denial.String()
+	}
+	return "permission denied - " + strings.Join(messages, ", ")
+}
@@ -0,0 +1,94 @@
+package queue
+
+import (
+	"container/heap"
+	"time"
+)
+
+// NewPriorityQueue creates a queue which returns the value with the highest priority first.
+// Values with the same priority are returned in FIFO order.
+func NewPriorityQueue[V any](priority func(V) int) Queue[V] {
+	return NewAgingPriorityQueue(priority, 0)
+}
+
+// NewAgingPriorityQueue is like NewPriorityQueue, but the priority of waiting values increases by one every aging interval,
+// so values with low priority cannot starve. Aging is disabled if the interval is not positive.
+func NewAgingPriorityQueue[V any](priority func(V) int, aging time.Duration) Queue[V] {
+	return &priorityQueue[V]{priority: priority, aging: aging, start: time.Now()}
+}
+
+type priorityQueue[V any] struct {
+	priority func(V) int
+	aging    time.Duration
+	start    time.Time
+
+	entries  priorityEntries[V]
+	sequence uint64
+}
+
+type priorityEntry[V any] struct {
+	Value V
+	// Since all waiting values age at the same rate, the effective priority at any time t,
+	// priority + (t - enqueued) / aging, orders values like priority - enqueued / aging.
+	Key      float64
+	Sequence uint64
+}
+
+func (q *priorityQueue[V]) Get() (result V) {
+	if q == nil || len(q.entries) == 0 {
+		return
+	}
+
+	return q.entries[0].Value
+}
+
+func (q *priorityQueue[V]) Pop() (result V) {
+	if q == nil || len(q.entries) == 0 {
+		return
+	}
+
+	return heap.Pop(&q.entries).(priorityEntry[V]).Value
+}
+
+func (q *priorityQueue[V]) Push(value V) {
+	key := float64(q.priority(value))
+	if q.aging > 0 {
+		key -= float64(time.Since(q.start)) / float64(q.aging)
+	}
+
+	heap.Push(&q.entries, priorityEntry[V]{Value: value, Key: key, Sequence: q.sequence})
+	q.sequence += 1
+}
+
+func (q *priorityQueue[V]) Count() uint {
+	return uint(len(q.entries))
+}
+
+type priorityEntries[V any] []priorityEntry[V]
+
+func (e priorityEntries[V]) Len() int {
+	return len(e)
+}
+
+func (e priorityEntries[V]) Less(i, j int) bool {
+	if e[i].Key != e[j].Key {
+		return e[i].Key > e[j].Key
+	}
+	return e[i].Sequence < e[j].Sequence
+}
+
+func (e priorityEntries[V]) Swap(i, j int) {
+	e[i], e[j] = e[j], e[i]
+}
+
+func (e *priorityEntries[V]) Push(x any) {
+	*e = append(*e, x.(priorityEntry[V]))
+}
+
+func (e *priorityEntries[V]) Pop() any {
+	old := *e
+	last := old[len(old)-1]
+	old[len(old)-1] = priorityEntry[V]{}
+	*e = old[:len(old)-1]
+	return last
+}
@@ -18,23 +18,37 @@ func Apply(value string, expressions []string) (result string, err error) {
 }
 
 func RegexpReplace(value string, expression string) (string, error) {
+	regex, substitution, err := compile(expression)
+	if err != nil {
+		return "", err
+	}
+
+	return regex.ReplaceAllString(value, substitution), nil
+}
+
+func Validate(expression string) error {
+	_, _, err := compile(expression)
+	return err
+}
+
+func compile(expression string) (*regexp.Regexp, string, error) {
 	runes := []rune(expression)
 
 	if len(runes) == 0 || runes[0] == '\\' {
-		return "", invalidExpressionError(expression)
+		return nil, "", invalidExpressionError(expression)
 	}
 
 	parts := splitUnescaped(expression, runes[0], '\\')
 	if len(parts) != 4 || parts[0] != "" || parts[3] != "" {
-		return "", invalidExpressionError(expression)
+		return nil, "", invalidExpressionError(expression)
 	}
 
 	regex, err := regexp.Compile(parts[1])
 	if err != nil {
-		return "", fmt.Errorf(`error compiling regexp for replace "%s" - %s`, parts[1], err)
+		return nil, "", fmt.Errorf(`error compiling regexp for replace "%s" - %s`, parts[1], err)
 	}
 
-	return regex.ReplaceAllString(value, parts[2]), nil
+	return regex, parts[2], nil
 }
 
 func splitUnescaped(s string, sep, esc rune) []string {
@@ -0,0 +1,172 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/reeveci/reeve-lib/plugin"
+	"github.com/reeveci/reeve-lib/schema"
+)
+
+const PLUGIN_NAME = "scheduler"
+
+// Settings with this prefix define a schedule, e.g. "SCHEDULE_NIGHTLY=0 2 * * *" creates the schedule "nightly"
+const SETTING_SCHEDULE_PREFIX = "SCHEDULE_"
+
+// Default time zone of all schedules
+const SETTING_TIMEZONE = "TIMEZONE"
+
+// Path of the file storing the last fire times, fire times are kept in memory if empty
+const SETTING_STATE_FILE = "STATE_FILE"
+
+// Interval for retrying after errors, defaults to one minute
+const SETTING_RETRY_INTERVAL = "RETRY_INTERVAL"
+
+// Serve runs the scheduler as a reeve plugin.
+func Serve(logger hclog.Logger) {
+	plugin.Serve(&plugin.PluginConfig{
+		Plugin: NewPlugin(logger),
+		Logger: logger,
+	})
+}
+
+func NewPlugin(logger hclog.Logger) *Plugin {
+	if logger == nil {
+		logger = hclog.NewNullLogger()
+	}
+	return &Plugin{Logger: logger}
+}
+
+// Plugin emits triggers for cron schedules configured in its settings.
+type Plugin struct {
+	Logger hclog.Logger
+
+	scheduler *Scheduler
+	cancel    context.CancelFunc
+	done      sync.WaitGroup
+}
+
+func (p *Plugin) Name() (string, error) {
+	return PLUGIN_NAME, nil
+}
+
+func (p *Plugin) Register(settings map[string]string, api plugin.ReeveAPI) (capabilities plugin.Capabilities, err error) {
+	schedules, err := ParseSettings(settings)
+	if err != nil {
+		return
+	}
+
+	var store Store
+	if path := settings[SETTING_STATE_FILE]; path != "" {
+		store = NewFileStore(path)
+	} else {
+		store = NewMemoryStore()
+	}
+
+	retryInterval := time.Minute
+	if value := settings[SETTING_RETRY_INTERVAL]; value != "" {
+		if retryInterval, err = time.ParseDuration(value); err != nil || retryInterval <= 0 {
+			err = fmt.Errorf(`invalid %s "%s"`, SETTING_RETRY_INTERVAL, value)
+			return
+		}
+	}
+
+	p.scheduler = New(schedules, store, api.NotifyTriggers)
+	p.scheduler.OnError = func(err error) {
+		p.Logger.Error("scheduler error", "error", err)
+	}
+
+	var ctx context.Context
+	ctx, p.cancel = context.WithCancel(context.Background())
+	p.done.Add(1)
+	go func() {
+		defer p.done.Done()
+		p.scheduler.Run(ctx, retryInterval)
+	}()
+
+	capabilities.CLIMethods = map[string]string{
+		"next": "[count] - show the upcoming fire times of all schedules",
+	}
+	return
+}
+
+func (p *Plugin) Unregister() error {
+	if p.cancel != nil {
+		p.cancel()
+		p.done.Wait()
+	}
+	return nil
+}
+
+func (p *Plugin) Message(source string, message schema.Message) error {
+	return nil
+}
+
+func (p *Plugin) Discover(trigger schema.Trigger) ([]schema.Pipeline, error) {
+	return nil, nil
+}
+
+func (p *Plugin) Resolve(env []string) (map[string]schema.Env, error) {
+	return map[string]schema.Env{}, nil
+}
+
+func (p *Plugin) Notify(status schema.PipelineStatus) error {
+	return nil
+}
+
+func (p *Plugin) CLIMethod(method string, args []string) (string, error) {
+	if method != "next" {
+		return "", fmt.Errorf(`unknown method "%s"`, method)
+	}
+	if p.scheduler == nil {
+		return "", schema.ERROR_UNAVAILABLE
+	}
+
+	count := 5
+	if len(args) > 0 {
+		var err error
+		if count, err = strconv.Atoi(args[0]); err != nil || count <= 0 {
+			return "", fmt.Errorf(`invalid count "%s"`, args[0])
+		}
+	}
+
+	now := p.scheduler.Now()
+	var result strings.Builder
+	for _, schedule := range p.scheduler.Schedules {
+		fmt.Fprintf(&result, "%s (%s):\n", schedule.Name, schedule.Cron)
+		for _, t := range schedule.Upcoming(now, count) {
+			fmt.Fprintf(&result, "  %s\n", t.Format(time.RFC3339))
+		}
+	}
+	return result.String(), nil
+}
+
+// ParseSettings creates the schedules defined in the plugin settings, sorted by name.
+func ParseSettings(settings map[string]string) ([]*Schedule, error) {
+	timeZone := settings[SETTING_TIMEZONE]
+
+	result := make([]*Schedule, 0)
+	for key, expression := range settings {
+		name, ok := strings.CutPrefix(key, SETTING_SCHEDULE_PREFIX)
+		if !ok || name == "" {
+			continue
+		}
+
+		schedule, err := NewSchedule(strings.ToLower(name), expression, timeZone)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, schedule)
+	}
+
+	slices.SortFunc(result, func(a, b *Schedule) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return result, nil
+}
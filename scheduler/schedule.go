@@ -0,0 +1,106 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/reeveci/reeve-lib/schema"
+	"github.com/robfig/cron/v3"
+)
+
+// Trigger key containing the name of the schedule
+const TRIGGER_SCHEDULE = "schedule"
+
+// Trigger key containing the scheduled fire time in RFC 3339 format
+const TRIGGER_TIME = "time"
+
+var parser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// Schedule must be created with NewSchedule.
+type Schedule struct {
+	Name string
+	// Cron expression with five fields or a descriptor like "@daily", optionally prefixed with "CRON_TZ=<zone>"
+	Cron string
+	// Time zone used if the expression does not specify one, defaults to UTC
+	TimeZone string
+	// Additional trigger keys
+	Trigger schema.Trigger
+
+	schedule cron.Schedule
+}
+
+// NewSchedule parses the cron expression in the given time zone.
+func NewSchedule(name, expression, timeZone string) (*Schedule, error) {
+	schedule, err := Parse(expression, timeZone)
+	if err != nil {
+		return nil, fmt.Errorf(`invalid schedule "%s" - %s`, name, err)
+	}
+	return &Schedule{Name: name, Cron: expression, TimeZone: timeZone, schedule: schedule}, nil
+}
+
+// Parse parses a cron expression, evaluating it in timeZone unless the expression specifies its own.
+func Parse(expression, timeZone string) (cron.Schedule, error) {
+	expression = strings.TrimSpace(expression)
+	if timeZone == "" {
+		timeZone = "UTC"
+	}
+	if _, err := time.LoadLocation(timeZone); err != nil {
+		return nil, fmt.Errorf(`invalid time zone "%s" - %s`, timeZone, err)
+	}
+	if !strings.HasPrefix(expression, "CRON_TZ=") && !strings.HasPrefix(expression, "TZ=") {
+		expression = "CRON_TZ=" + timeZone + " " + expression
+	}
+
+	schedule, err := parser.Parse(expression)
+	if err != nil {
+		return nil, fmt.Errorf(`invalid cron expression "%s" - %s`, expression, err)
+	}
+	return schedule, nil
+}
+
+// Next returns the first fire time after t.
+func (s *Schedule) Next(t time.Time) time.Time {
+	return s.schedule.Next(t)
+}
+
+// Upcoming returns the next n fire times after t.
+func (s *Schedule) Upcoming(t time.Time, n int) []time.Time {
+	result := make([]time.Time, 0, n)
+	for range n {
+		t = s.schedule.Next(t)
+		if t.IsZero() {
+			break
+		}
+		result = append(result, t)
+	}
+	return result
+}
+
+// Due returns the latest fire time in (last, now], or false if the schedule is not due.
+// Missed fire times are coalesced, so a schedule fires at most once after a downtime.
+func (s *Schedule) Due(last, now time.Time) (time.Time, bool) {
+	next := s.schedule.Next(last)
+	if next.IsZero() || next.After(now) {
+		return time.Time{}, false
+	}
+
+	for {
+		following := s.schedule.Next(next)
+		if following.IsZero() || following.After(now) {
+			return next, true
+		}
+		next = following
+	}
+}
+
+// TriggerFor returns the trigger emitted when the schedule fires at t.
+func (s *Schedule) TriggerFor(t time.Time) schema.Trigger {
+	result := make(schema.Trigger, len(s.Trigger)+2)
+	for key, value := range s.Trigger {
+		result[key] = value
+	}
+	result[TRIGGER_SCHEDULE] = s.Name
+	result[TRIGGER_TIME] = t.Format(time.RFC3339)
+	return result
+}
@@ -0,0 +1,106 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/reeveci/reeve-lib/schema"
+)
+
+type Scheduler struct {
+	Schedules []*Schedule
+	Store     Store
+	// Notify receives the triggers of all schedules which fired at the same time, usually ReeveAPI.NotifyTriggers
+	Notify func(triggers []schema.Trigger) error
+	// OnError is called for errors while running, may be nil
+	OnError func(err error)
+
+	// Now returns the current time and may be replaced, e.g. for tests
+	Now func() time.Time
+}
+
+func New(schedules []*Schedule, store Store, notify func(triggers []schema.Trigger) error) *Scheduler {
+	return &Scheduler{
+		Schedules: schedules,
+		Store:     store,
+		Notify:    notify,
+		Now:       time.Now,
+	}
+}
+
+// Tick fires all schedules which are due and returns the time of the next fire.
+//
+// Schedules without a stored fire time start at the current time, so they do not fire for the past.
+// The fire time is stored before the trigger is sent, so a schedule never fires twice for the same time,
+// even if the process is restarted. If sending fails, the trigger is not repeated.
+func (s *Scheduler) Tick() (next time.Time, err error) {
+	now := s.Now()
+	triggers := make([]schema.Trigger, 0)
+
+	for _, schedule := range s.Schedules {
+		last, ok, storeErr := s.Store.LastFired(schedule.Name)
+		if storeErr != nil {
+			err = storeErr
+			continue
+		}
+		if !ok {
+			if storeErr := s.Store.SetLastFired(schedule.Name, now); storeErr != nil {
+				err = storeErr
+				continue
+			}
+			last = now
+		}
+
+		if due, ok := schedule.Due(last, now); ok {
+			if storeErr := s.Store.SetLastFired(schedule.Name, due); storeErr != nil {
+				err = storeErr
+				continue
+			}
+			triggers = append(triggers, schedule.TriggerFor(due))
+			last = due
+		}
+
+		if following := schedule.Next(last); !following.IsZero() && (next.IsZero() || following.Before(next)) {
+			next = following
+		}
+	}
+
+	if len(triggers) > 0 {
+		if notifyErr := s.Notify(triggers); notifyErr != nil {
+			err = fmt.Errorf("error sending scheduled triggers - %s", notifyErr)
+		}
+	}
+
+	return
+}
+
+// Run fires the schedules until the context is cancelled.
+// Store errors are retried after retryInterval.
+func (s *Scheduler) Run(ctx context.Context, retryInterval time.Duration) {
+	for {
+		next, err := s.Tick()
+		if err != nil && s.OnError != nil {
+			s.OnError(err)
+		}
+
+		wait := retryInterval
+		if !next.IsZero() {
+			if untilNext := next.Sub(s.Now()); err == nil || untilNext < wait {
+				wait = untilNext
+			}
+		} else if err == nil {
+			// no schedule will ever fire again
+			<-ctx.Done()
+			return
+		}
+
+		timer := time.NewTimer(max(wait, 0))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Store persists the last fire time of every schedule.
+type Store interface {
+	LastFired(name string) (time.Time, bool, error)
+	SetLastFired(name string, t time.Time) error
+}
+
+func NewMemoryStore() Store {
+	return &memoryStore{times: make(map[string]time.Time)}
+}
+
+type memoryStore struct {
+	times map[string]time.Time
+	lock  sync.Mutex
+}
+
+func (s *memoryStore) LastFired(name string) (time.Time, bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	t, ok := s.times[name]
+	return t, ok, nil
+}
+
+func (s *memoryStore) SetLastFired(name string, t time.Time) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.times[name] = t
+	return nil
+}
+
+// NewFileStore creates a store which keeps the fire times in a JSON file.
+// The file is replaced atomically, so it stays intact if the process is killed while writing.
+func NewFileStore(path string) Store {
+	return &fileStore{path: path}
+}
+
+type fileStore struct {
+	path string
+	lock sync.Mutex
+}
+
+func (s *fileStore) LastFired(name string) (time.Time, bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	times, err := s.read()
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	t, ok := times[name]
+	return t, ok, nil
+}
+
+func (s *fileStore) SetLastFired(name string, t time.Time) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	times, err := s.read()
+	if err != nil {
+		return err
+	}
+	times[name] = t
+
+	data, err := json.MarshalIndent(times, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding schedule state - %s", err)
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("error writing schedule state - %s", err)
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), s.path)
+	}
+	if err != nil {
+		return fmt.Errorf("error writing schedule state - %s", err)
+	}
+	return nil
+}
+
+func (s *fileStore) read() (map[string]time.Time, error) {
+	times := make(map[string]time.Time)
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return times, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading schedule state - %s", err)
+	}
+
+	if err := json.Unmarshal(data, &times); err != nil {
+		return nil, fmt.Errorf("error parsing schedule state - %s", err)
+	}
+	return times, nil
+}
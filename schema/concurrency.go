@@ -0,0 +1,85 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/reeveci/reeve-lib/interpolation"
+)
+
+type ConcurrencyPolicy string
+
+// Wait until all pipelines of the group finished
+const CONCURRENCY_QUEUE ConcurrencyPolicy = "queue"
+
+// Cancel all other pipelines of the group
+const CONCURRENCY_CANCEL_IN_PROGRESS ConcurrencyPolicy = "cancel-in-progress"
+
+// Skip the new pipeline if another pipeline of the group is running
+const CONCURRENCY_SKIP_IF_RUNNING ConcurrencyPolicy = "skip-if-running"
+
+var ConcurrencyPolicies = []ConcurrencyPolicy{CONCURRENCY_QUEUE, CONCURRENCY_CANCEL_IN_PROGRESS, CONCURRENCY_SKIP_IF_RUNNING}
+
+// Sources which can be referenced in concurrency groups
+var ConcurrencySources = []string{interpolation.SOURCE_ENV, interpolation.SOURCE_FACT}
+
+type Concurrency struct {
+	// Pipelines with the same group are not run concurrently, e.g. "deploy-${fact:branch}"
+	Group  string            `json:"group" yaml:"group"`
+	Policy ConcurrencyPolicy `json:"policy" yaml:"policy"`
+}
+
+func (c Concurrency) GetPolicy() ConcurrencyPolicy {
+	if c.Policy == "" {
+		return CONCURRENCY_QUEUE
+	}
+	return c.Policy
+}
+
+// ConcurrencyGroup returns the interpolated concurrency group of the pipeline or "" if it has none.
+// Facts with multiple values are joined with ",".
+func (p Pipeline) ConcurrencyGroup() (string, error) {
+	if p.Concurrency == nil || p.Concurrency.Group == "" {
+		return "", nil
+	}
+
+	segments, err := interpolation.ParseSources(p.Concurrency.Group, ConcurrencySources)
+	if err != nil {
+		return "", fmt.Errorf("invalid concurrency group - %s", err)
+	}
+
+	var result strings.Builder
+	for _, segment := range segments {
+		var value string
+		switch segment.Source {
+		case "":
+			result.WriteString(segment.Literal)
+			continue
+
+		case interpolation.SOURCE_ENV:
+			env, ok := p.Env[segment.Key]
+			if !ok {
+				return "", fmt.Errorf(`missing env "%s" for concurrency group`, segment.Key)
+			}
+			if env.Secret {
+				return "", fmt.Errorf(`secret env "%s" must not be used in concurrency group`, segment.Key)
+			}
+			value = env.Value
+
+		case interpolation.SOURCE_FACT:
+			fact, ok := p.Facts[segment.Key]
+			if !ok {
+				return "", fmt.Errorf(`missing fact "%s" for concurrency group`, segment.Key)
+			}
+			value = strings.Join(fact, ",")
+		}
+
+		value, err = applyPipeline(value, segment.Replace, segment.Transform)
+		if err != nil {
+			return "", fmt.Errorf("error interpolating concurrency group - %s", err)
+		}
+		result.WriteString(value)
+	}
+
+	return result.String(), nil
+}
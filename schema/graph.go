@@ -0,0 +1,218 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+)
+
+// StepGraph describes the dependencies between the steps of a pipeline.
+//
+// A step which lists other steps in Needs depends on exactly these steps.
+// A step without Needs depends on all steps of the preceding stages, where stages are ordered by their first appearance.
+type StepGraph struct {
+	Steps []Step
+	// Dependencies lists the indices of all steps each step depends on
+	Dependencies [][]int
+	// Dependents lists the indices of all steps depending on each step
+	Dependents [][]int
+
+	order []int
+}
+
+func NewStepGraph(steps []Step) (*StepGraph, error) {
+	v := &validator{}
+	graph := v.buildStepGraph(steps)
+	if err := v.errors.Err(); err != nil {
+		return nil, err
+	}
+	return graph, nil
+}
+
+// Order returns the step indices in a valid execution order, preferring the definition order.
+func (g *StepGraph) Order() []int {
+	result := make([]int, len(g.order))
+	copy(result, g.order)
+	return result
+}
+
+// Ready returns the indices of all steps which have not completed yet but whose dependencies have all completed.
+func (g *StepGraph) Ready(completed map[int]bool) []int {
+	result := make([]int, 0, len(g.Steps))
+	for i, dependencies := range g.Dependencies {
+		if completed[i] {
+			continue
+		}
+		ready := true
+		for _, dependency := range dependencies {
+			if !completed[dependency] {
+				ready = false
+				break
+			}
+		}
+		if ready {
+			result = append(result, i)
+		}
+	}
+	return result
+}
+
+// ReadyByName works like Ready, but identifies steps by their names.
+func (g *StepGraph) ReadyByName(completed []string) []string {
+	completedNames := make(map[string]bool, len(completed))
+	for _, name := range completed {
+		completedNames[name] = true
+	}
+
+	completedSteps := make(map[int]bool, len(completed))
+	for i, step := range g.Steps {
+		if completedNames[step.Name] {
+			completedSteps[i] = true
+		}
+	}
+
+	ready := g.Ready(completedSteps)
+	result := make([]string, len(ready))
+	for i, step := range ready {
+		result[i] = g.Steps[step].Name
+	}
+	return result
+}
+
+// dependsOn reports whether step directly or indirectly depends on dependency.
+func (g *StepGraph) dependsOn(step, dependency int) bool {
+	visited := make(map[int]bool)
+	pending := []int{step}
+	for len(pending) > 0 {
+		current := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		for _, next := range g.Dependencies[current] {
+			if next == dependency {
+				return true
+			}
+			if !visited[next] {
+				visited[next] = true
+				pending = append(pending, next)
+			}
+		}
+	}
+	return false
+}
+
+func (v *validator) buildStepGraph(steps []Step) *StepGraph {
+	graph := &StepGraph{
+		Steps:        steps,
+		Dependencies: make([][]int, len(steps)),
+		Dependents:   make([][]int, len(steps)),
+	}
+
+	names := make(map[string]int, len(steps))
+	stages := make(map[string][]int)
+	stageOrder := make([]string, 0)
+	for i, step := range steps {
+		if _, ok := names[step.Name]; !ok && step.Name != "" {
+			names[step.Name] = i
+		}
+
+		stage := step.Stage
+		if stage == "" {
+			stage = DEFAULT_STAGE
+		}
+		if _, ok := stages[stage]; !ok {
+			stageOrder = append(stageOrder, stage)
+		}
+		stages[stage] = append(stages[stage], i)
+	}
+
+	var previous []int
+	for _, stage := range stageOrder {
+		for _, i := range stages[stage] {
+			if len(steps[i].Needs) > 0 {
+				continue
+			}
+			graph.Dependencies[i] = append(graph.Dependencies[i], previous...)
+		}
+		previous = append(previous, stages[stage]...)
+	}
+
+	for i, step := range steps {
+		seen := make(map[int]bool, len(step.Needs))
+		for j, name := range step.Needs {
+			dependency, ok := names[name]
+			if !ok {
+				v.add(IndexPath(KeyPath(IndexPath("steps", i), "needs"), j), `unknown step "%s"`, name)
+				continue
+			}
+			if !seen[dependency] {
+				seen[dependency] = true
+				graph.Dependencies[i] = append(graph.Dependencies[i], dependency)
+			}
+		}
+	}
+
+	for i, dependencies := range graph.Dependencies {
+		for _, dependency := range dependencies {
+			graph.Dependents[dependency] = append(graph.Dependents[dependency], i)
+		}
+	}
+
+	// Kahn's algorithm, always picking the lowest pending index
+	remaining := make([]int, len(steps))
+	for i, dependencies := range graph.Dependencies {
+		remaining[i] = len(dependencies)
+	}
+	done := make([]bool, len(steps))
+	graph.order = make([]int, 0, len(steps))
+	for len(graph.order) < len(steps) {
+		next := -1
+		for i := range steps {
+			if !done[i] && remaining[i] == 0 {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			break
+		}
+		done[next] = true
+		graph.order = append(graph.order, next)
+		for _, dependent := range graph.Dependents[next] {
+			remaining[dependent] -= 1
+		}
+	}
+
+	if len(graph.order) < len(steps) {
+		for i := range steps {
+			if !done[i] {
+				cycle := graph.findCycle(i, done)
+				names := make([]string, len(cycle))
+				for j, step := range cycle {
+					names[j] = fmt.Sprintf(`"%s"`, steps[step].Name)
+				}
+				v.add(KeyPath(IndexPath("steps", cycle[0]), "needs"), "dependency cycle %s", strings.Join(names, " -> "))
+				break
+			}
+		}
+	}
+
+	return graph
+}
+
+// findCycle follows unfinished dependencies starting at step until a step is visited twice.
+func (g *StepGraph) findCycle(step int, done []bool) []int {
+	visited := make(map[int]int)
+	path := make([]int, 0)
+	for {
+		if start, ok := visited[step]; ok {
+			return append(path[start:], step)
+		}
+		visited[step] = len(path)
+		path = append(path, step)
+
+		for _, dependency := range g.Dependencies[step] {
+			if !done[dependency] {
+				step = dependency
+				break
+			}
+		}
+	}
+}
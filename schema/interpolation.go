@@ -0,0 +1,145 @@
+package schema
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/shlex"
+	"github.com/reeveci/reeve-lib/interpolation"
+)
+
+// Private use characters which mark references while splitting commands
+const referenceStart = '\uE000'
+const referenceEnd = '\uE001'
+
+// parseInterpolation splits a literal into LiteralParam segments, which must not be interpolated again,
+// and EnvParam, VarParam or OutputParam references.
+func parseInterpolation(value string) ([]RawParam, error) {
+	segments, err := interpolation.Parse(value)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]RawParam, len(segments))
+	for i, segment := range segments {
+		switch segment.Source {
+		case "":
+			result[i] = LiteralParam(segment.Literal)
+
+		case interpolation.SOURCE_ENV:
+			result[i] = EnvParam{Env: segment.Key, Replace: segment.Replace, Transform: segment.Transform}
+
+		case interpolation.SOURCE_VAR:
+			result[i] = VarParam{Var: segment.Key, Replace: segment.Replace, Transform: segment.Transform}
+
+		case interpolation.SOURCE_OUTPUT:
+			index := strings.LastIndex(segment.Key, ".")
+			if index < 0 {
+				return nil, fmt.Errorf(`invalid output reference "%s" - expected "<step>.<output>"`, segment.Key)
+			}
+			result[i] = OutputParam{Step: segment.Key[:index], Output: segment.Key[index+1:], Replace: segment.Replace, Transform: segment.Transform}
+
+		default:
+			return nil, fmt.Errorf(`unsupported source "%s"`, segment.Source)
+		}
+	}
+	return result, nil
+}
+
+// splitCommand splits a shell command into arguments, each consisting of LiteralParam segments and references.
+// References are parsed before splitting and kept as opaque tokens, so quoting and whitespace inside
+// a reference are never processed by the shell splitter.
+func splitCommand(command string) ([][]RawParam, error) {
+	segments, err := parseInterpolation(command)
+	if err != nil {
+		return nil, err
+	}
+
+	var builder strings.Builder
+	references := make([]RawParam, 0)
+	for _, segment := range segments {
+		if literal, ok := segment.(LiteralParam); ok {
+			if strings.ContainsAny(string(literal), string([]rune{referenceStart, referenceEnd})) {
+				return nil, fmt.Errorf("command contains reserved characters")
+			}
+			builder.WriteString(string(literal))
+			continue
+		}
+		builder.WriteRune(referenceStart)
+		builder.WriteString(strconv.Itoa(len(references)))
+		builder.WriteRune(referenceEnd)
+		references = append(references, segment)
+	}
+
+	args, err := shlex.Split(builder.String())
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([][]RawParam, len(args))
+	for i, arg := range args {
+		result[i] = make([]RawParam, 0, 1)
+		for arg != "" {
+			start := strings.IndexRune(arg, referenceStart)
+			if start < 0 {
+				result[i] = append(result[i], LiteralParam(arg))
+				break
+			}
+			if start > 0 {
+				result[i] = append(result[i], LiteralParam(arg[:start]))
+			}
+			arg = arg[start+len(string(referenceStart)):]
+
+			end := strings.IndexRune(arg, referenceEnd)
+			index, err := strconv.Atoi(arg[:end])
+			if err != nil {
+				return nil, err
+			}
+			result[i] = append(result[i], references[index])
+			arg = arg[end+len(string(referenceEnd)):]
+		}
+	}
+	return result, nil
+}
+
+// references returns all params referenced by a param, including those interpolated into literals.
+// Invalid params are skipped.
+func references(rawParam RawParam) []RawParam {
+	if value, ok := rawParam.(Command); ok {
+		command, err := parseCommand(value.Value)
+		if err != nil {
+			return nil
+		}
+		if args, ok := command.(LiteralCommand); ok {
+			result := make([]RawParam, 0)
+			for _, arg := range args {
+				result = append(result, references(LiteralParam(arg))...)
+			}
+			return result
+		}
+		rawParam = command
+	}
+
+	param, err := parseParam(rawParam)
+	if err != nil {
+		return nil
+	}
+
+	literal, ok := param.(LiteralParam)
+	if !ok {
+		return []RawParam{param}
+	}
+
+	segments, err := parseInterpolation(string(literal))
+	if err != nil {
+		return nil
+	}
+	result := make([]RawParam, 0, len(segments))
+	for _, segment := range segments {
+		if _, ok := segment.(LiteralParam); !ok {
+			result = append(result, segment)
+		}
+	}
+	return result
+}
@@ -0,0 +1,51 @@
+package schema
+
+import (
+	"fmt"
+
+	"github.com/reeveci/reeve-lib/labels"
+)
+
+// WorkerSelector returns the combined selector of the pipeline and all of its steps,
+// since all steps of a pipeline are run by the same worker.
+func (p PipelineDefinition) WorkerSelector() (labels.Selector, error) {
+	result, err := labels.Parse(p.Selector...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, step := range p.Steps {
+		selector, err := labels.Parse(step.Selector...)
+		if err != nil {
+			return nil, fmt.Errorf(`invalid selector for step "%s" - %s`, step.Name, err)
+		}
+		result = append(result, selector...)
+	}
+
+	return result, nil
+}
+
+// MatchesWorker reports whether a worker advertising the given labels may receive the pipeline.
+func (p PipelineDefinition) MatchesWorker(workerLabels map[string]string) (bool, error) {
+	selector, err := p.WorkerSelector()
+	if err != nil {
+		return false, err
+	}
+	return selector.Matches(workerLabels), nil
+}
+
+// MatchesWorker reports whether a worker of the given group advertising the given labels may receive the pipeline.
+func (s *PipelineStatus) MatchesWorker(workerGroup string, workerLabels map[string]string) (bool, error) {
+	group := s.WorkerGroup
+	if group == "" {
+		group = DEFAULT_WORKER_GROUP
+	}
+	if workerGroup == "" {
+		workerGroup = DEFAULT_WORKER_GROUP
+	}
+	if group != workerGroup {
+		return false, nil
+	}
+
+	return s.Pipeline.MatchesWorker(workerLabels)
+}
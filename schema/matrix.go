@@ -0,0 +1,165 @@
+package schema
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// ExpandMatrix replaces every step with a matrix by one step per matrix combination.
+//
+// The combinations are the cartesian product of all axes, without those matching an exclude entry, followed by all include entries.
+// An entry matches a combination if all of its keys have the same value in the combination.
+// Each expanded step is named "<name> (<key>=<value>, ...)" with keys in alphabetical order
+// and receives the combination as vars prefixed with MATRIX_VAR_PREFIX.
+// Needs referencing an expanded step are replaced by the names of all of its expansions.
+func ExpandMatrix(steps []Step) ([]Step, error) {
+	result := make([]Step, 0, len(steps))
+	expanded := make(map[string][]string)
+
+	for i, step := range steps {
+		if step.Matrix == nil {
+			result = append(result, step)
+			continue
+		}
+
+		combinations, err := step.Matrix.Combinations()
+		if err != nil {
+			return nil, fmt.Errorf("invalid matrix for step %s - %s", stepLabel(i, step), err)
+		}
+
+		names := make([]string, 0, len(combinations))
+		for _, combination := range combinations {
+			expandedStep := step
+			expandedStep.Matrix = nil
+			expandedStep.Name = matrixStepName(step.Name, combination)
+
+			expandedStep.Vars = make(map[string]Var, len(step.Vars)+len(combination))
+			for key, value := range step.Vars {
+				expandedStep.Vars[key] = value
+			}
+			for key, value := range combination {
+				expandedStep.Vars[MATRIX_VAR_PREFIX+key] = Var(value)
+			}
+
+			result = append(result, expandedStep)
+			names = append(names, expandedStep.Name)
+		}
+		if step.Name != "" {
+			expanded[step.Name] = names
+		}
+	}
+
+	if len(expanded) > 0 {
+		for i, step := range result {
+			if len(step.Needs) == 0 {
+				continue
+			}
+			needs := make([]string, 0, len(step.Needs))
+			for _, name := range step.Needs {
+				if names, ok := expanded[name]; ok {
+					needs = append(needs, names...)
+				} else {
+					needs = append(needs, name)
+				}
+			}
+			result[i].Needs = needs
+		}
+	}
+
+	return result, nil
+}
+
+// Combinations returns all combinations of the matrix in a deterministic order.
+func (m Matrix) Combinations() ([]map[string]string, error) {
+	axes := slices.Sorted(maps.Keys(m.Axes))
+	for _, axis := range axes {
+		if axis == "" {
+			return nil, fmt.Errorf("axis name must not be empty")
+		}
+		if len(m.Axes[axis]) == 0 {
+			return nil, fmt.Errorf(`axis "%s" has no values`, axis)
+		}
+	}
+
+	result := make([]map[string]string, 0)
+	if len(axes) > 0 {
+		result = append(result, map[string]string{})
+		for _, axis := range axes {
+			next := make([]map[string]string, 0, len(result)*len(m.Axes[axis]))
+			for _, combination := range result {
+				for _, value := range m.Axes[axis] {
+					extended := maps.Clone(combination)
+					extended[axis] = value
+					next = append(next, extended)
+				}
+			}
+			result = next
+		}
+	}
+
+	result = slices.DeleteFunc(result, func(combination map[string]string) bool {
+		for _, exclude := range m.Exclude {
+			if matrixEntryMatches(exclude, combination) {
+				return true
+			}
+		}
+		return false
+	})
+
+	for _, include := range m.Include {
+		if len(include) == 0 {
+			return nil, fmt.Errorf("include entries must not be empty")
+		}
+		if !slices.ContainsFunc(result, func(combination map[string]string) bool { return maps.Equal(combination, include) }) {
+			result = append(result, maps.Clone(include))
+		}
+	}
+
+	if len(result) == 0 {
+		return nil, fmt.Errorf("matrix has no combinations")
+	}
+
+	return result, nil
+}
+
+func matrixEntryMatches(entry, combination map[string]string) bool {
+	for key, value := range entry {
+		if actual, ok := combination[key]; !ok || actual != value {
+			return false
+		}
+	}
+	return true
+}
+
+func matrixStepName(name string, combination map[string]string) string {
+	parts := make([]string, 0, len(combination))
+	for _, key := range slices.Sorted(maps.Keys(combination)) {
+		parts = append(parts, key+"="+combination[key])
+	}
+	return fmt.Sprintf("%s (%s)", name, strings.Join(parts, ", "))
+}
+
+func stepLabel(index int, step Step) string {
+	if step.Name == "" {
+		return fmt.Sprintf("at index %v", index)
+	}
+	return fmt.Sprintf(`"%s"`, step.Name)
+}
+
+// MergeVars returns vars overlaid with the vars of the step.
+func (step Step) MergeVars(vars map[string]Var) map[string]Var {
+	if len(step.Vars) == 0 {
+		return vars
+	}
+
+	result := make(map[string]Var, len(vars)+len(step.Vars))
+	for key, value := range vars {
+		result[key] = value
+	}
+	for key, value := range step.Vars {
+		result[key] = value
+	}
+	return result
+}
@@ -0,0 +1,58 @@
+package schema
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Outputs maps the declared output names of a step to their values.
+type Outputs map[string]string
+
+var outputNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// OutputKey identifies an output of a step, e.g. when reporting unresolved outputs.
+// Output names may not contain dots, so the key can be split at the last dot.
+func OutputKey(step, output string) string {
+	return step + "." + output
+}
+
+func ValidateOutputName(name string) error {
+	if !outputNamePattern.MatchString(name) {
+		return fmt.Errorf(`invalid output name "%s" - output names must start with a letter or underscore and may only contain letters, digits, underscores and dashes`, name)
+	}
+	return nil
+}
+
+// ParseOutputs reads the outputs written by a step in the form "name=value", one per line.
+// Empty lines are ignored. Only declared outputs may be written, later lines override earlier ones.
+func ParseOutputs(r io.Reader, declared []string) (Outputs, error) {
+	result := make(Outputs)
+
+	s := bufio.NewScanner(r)
+	line := 0
+	for s.Scan() {
+		line += 1
+		text := strings.TrimSuffix(s.Text(), "\r")
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(text, "=")
+		if !ok {
+			return nil, fmt.Errorf(`invalid output on line %v - expected "name=value"`, line)
+		}
+		if !slices.Contains(declared, name) {
+			return nil, fmt.Errorf(`invalid output on line %v - output "%s" is not declared`, line, name)
+		}
+		result[name] = value
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
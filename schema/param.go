@@ -0,0 +1,243 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Param is a decoded param which holds nil, LiteralParam, EnvParam, VarParam or OutputParam.
+//
+// It decodes from JSON and YAML rejecting unknown keys and encodes to the same shape again.
+// Values of the any-based shapes (e.g. map[string]any) are accepted as well and normalized where possible.
+type Param struct {
+	Value RawParam
+}
+
+// Command is a decoded command which holds LiteralCommand or any value of Param.
+type Command struct {
+	Value RawCommand
+}
+
+func NewParam(raw RawParam) (Param, error) {
+	value, err := parseParam(raw)
+	if err != nil {
+		return Param{}, err
+	}
+	return Param{Value: value}, nil
+}
+
+func NewCommand(raw RawCommand) (Command, error) {
+	value, err := parseCommand(raw)
+	if err != nil {
+		return Command{}, err
+	}
+	return Command{Value: value}, nil
+}
+
+func (p Param) IsZero() bool {
+	return p.Value == nil
+}
+
+func (c Command) IsZero() bool {
+	return c.Value == nil
+}
+
+func (p Param) MarshalJSON() ([]byte, error) {
+	value, err := parseParam(p.Value)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(value)
+}
+
+func (c Command) MarshalJSON() ([]byte, error) {
+	value, err := parseCommand(c.Value)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(value)
+}
+
+func (p *Param) UnmarshalJSON(data []byte) (err error) {
+	p.Value, err = decodeJSONParam(data)
+	return
+}
+
+func (c *Command) UnmarshalJSON(data []byte) (err error) {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		var command LiteralCommand
+		if err := json.Unmarshal(data, &command); err != nil {
+			return fmt.Errorf("command may only contain strings - %s", err)
+		}
+		c.Value = command
+		return nil
+	}
+
+	c.Value, err = decodeJSONParam(data)
+	return
+}
+
+func (p Param) MarshalYAML() (any, error) {
+	return parseParam(p.Value)
+}
+
+func (c Command) MarshalYAML() (any, error) {
+	return parseCommand(c.Value)
+}
+
+func (p *Param) UnmarshalYAML(node *yaml.Node) (err error) {
+	p.Value, err = decodeYAMLParam(node)
+	return
+}
+
+func (c *Command) UnmarshalYAML(node *yaml.Node) (err error) {
+	if node.Kind == yaml.SequenceNode {
+		var command LiteralCommand
+		if err := node.Decode(&command); err != nil {
+			return fmt.Errorf("command may only contain strings - %s", err)
+		}
+		c.Value = command
+		return nil
+	}
+
+	c.Value, err = decodeYAMLParam(node)
+	return
+}
+
+// Gob is used for plugin communication, so params are encoded like JSON and do not need to be registered.
+
+func (p Param) GobEncode() ([]byte, error) {
+	return p.MarshalJSON()
+}
+
+func (p *Param) GobDecode(data []byte) error {
+	return p.UnmarshalJSON(data)
+}
+
+func (c Command) GobEncode() ([]byte, error) {
+	return c.MarshalJSON()
+}
+
+func (c *Command) GobDecode(data []byte) error {
+	return c.UnmarshalJSON(data)
+}
+
+// paramTypes maps the key identifying a reference param to its type.
+var paramTypes = map[string]reflect.Type{
+	"env":    reflect.TypeFor[EnvParam](),
+	"var":    reflect.TypeFor[VarParam](),
+	"output": reflect.TypeFor[OutputParam](),
+}
+
+func decodeJSONParam(data []byte) (RawParam, error) {
+	data = bytes.TrimSpace(data)
+
+	if len(data) == 0 || data[0] != '{' {
+		var value any
+		if err := json.Unmarshal(data, &value); err != nil {
+			return nil, err
+		}
+		switch value := value.(type) {
+		case nil:
+			return nil, nil
+		case string:
+			return LiteralParam(value), nil
+		case float64, bool:
+			return LiteralParam(bytes.TrimSpace(data)), nil
+		default:
+			return nil, fmt.Errorf("param must be a string or an object but is %T (%v)", value, value)
+		}
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	t, err := paramType(fields)
+	if err != nil {
+		return nil, err
+	}
+	value := reflect.New(t)
+	if err := json.Unmarshal(data, value.Interface()); err != nil {
+		return nil, err
+	}
+	return value.Elem().Interface(), nil
+}
+
+func decodeYAMLParam(node *yaml.Node) (RawParam, error) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if node.Tag == "!!null" {
+			return nil, nil
+		}
+		return LiteralParam(node.Value), nil
+
+	case yaml.MappingNode:
+		fields := make(map[string]*yaml.Node, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			fields[node.Content[i].Value] = node.Content[i+1]
+		}
+
+		t, err := paramType(fields)
+		if err != nil {
+			return nil, fmt.Errorf("line %v: %s", node.Line, err)
+		}
+		value := reflect.New(t)
+		if err := node.Decode(value.Interface()); err != nil {
+			return nil, err
+		}
+		return value.Elem().Interface(), nil
+
+	case yaml.AliasNode:
+		return decodeYAMLParam(node.Alias)
+
+	default:
+		return nil, fmt.Errorf("line %v: param must be a string or a mapping", node.Line)
+	}
+}
+
+func paramType[V any](fields map[string]V) (reflect.Type, error) {
+	var result reflect.Type
+	var resultKey string
+	for _, key := range slices.Sorted(maps.Keys(fields)) {
+		t, ok := paramTypes[key]
+		if !ok {
+			continue
+		}
+		if result != nil {
+			return nil, fmt.Errorf(`param may only reference one of %s but references "%s" and "%s"`, strings.Join(slices.Sorted(maps.Keys(paramTypes)), ", "), resultKey, key)
+		}
+		result, resultKey = t, key
+	}
+	if result == nil {
+		return nil, fmt.Errorf("param must reference one of %s", strings.Join(slices.Sorted(maps.Keys(paramTypes)), ", "))
+	}
+
+	known := knownParamKeys(result)
+	for _, key := range slices.Sorted(maps.Keys(fields)) {
+		if !known[key] {
+			return nil, fmt.Errorf(`unknown key "%s" in %s param`, key, resultKey)
+		}
+	}
+
+	return result, nil
+}
+
+// knownParamKeys returns the keys of all fields of a param type.
+func knownParamKeys(t reflect.Type) map[string]bool {
+	result := make(map[string]bool, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		result[name] = true
+	}
+	return result
+}
@@ -0,0 +1,129 @@
+package schema
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+type FailureReason string
+
+const FAILURE_EXIT_CODE FailureReason = "exit code"
+const FAILURE_TIMEOUT FailureReason = "timeout"
+const FAILURE_ERROR FailureReason = "error"
+
+type RetryPolicy struct {
+	// Maximum number of attempts including the first one
+	Attempts uint `json:"attempts" yaml:"attempts"`
+	// Delay before the first retry, e.g. "10s"
+	Backoff string `json:"backoff" yaml:"backoff"`
+	// Factor the delay is multiplied with after every retry, defaults to 1
+	BackoffFactor float64 `json:"backoffFactor" yaml:"backoffFactor"`
+	// Upper limit for the delay
+	MaxBackoff string `json:"maxBackoff" yaml:"maxBackoff"`
+	// Only retry if the task exits with one of these codes, if empty any failure is retried
+	ExitCodes []int `json:"exitCodes" yaml:"exitCodes"`
+}
+
+func (step Step) GetTimeout() (time.Duration, error) {
+	return parseTimeout(step.Timeout)
+}
+
+func (setup Setup) GetTimeout() (time.Duration, error) {
+	return parseTimeout(setup.Timeout)
+}
+
+// parseTimeout returns 0 if no timeout is set.
+func parseTimeout(timeout string) (time.Duration, error) {
+	if timeout == "" {
+		return 0, nil
+	}
+	result, err := time.ParseDuration(timeout)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timeout - %s", err)
+	}
+	if result <= 0 {
+		return 0, fmt.Errorf("timeout must be positive but is %s", timeout)
+	}
+	return result, nil
+}
+
+func (r *RetryPolicy) MaxAttempts() uint {
+	if r == nil || r.Attempts == 0 {
+		return 1
+	}
+	return r.Attempts
+}
+
+func (r *RetryPolicy) Validate() error {
+	if r == nil {
+		return nil
+	}
+	if _, err := parseBackoff(r.Backoff); err != nil {
+		return fmt.Errorf("invalid backoff - %s", err)
+	}
+	if _, err := parseBackoff(r.MaxBackoff); err != nil {
+		return fmt.Errorf("invalid maxBackoff - %s", err)
+	}
+	if r.BackoffFactor < 0 {
+		return fmt.Errorf("backoffFactor must not be negative")
+	}
+	return nil
+}
+
+// ShouldRetry reports whether another attempt should be made after the given (1-based) attempt failed.
+func (r *RetryPolicy) ShouldRetry(attempt uint, reason FailureReason, exitCode int) bool {
+	if attempt >= r.MaxAttempts() {
+		return false
+	}
+	if len(r.ExitCodes) == 0 {
+		return true
+	}
+	return reason == FAILURE_EXIT_CODE && slices.Contains(r.ExitCodes, exitCode)
+}
+
+// Delay returns the time to wait after the given (1-based) attempt failed.
+func (r *RetryPolicy) Delay(attempt uint) (time.Duration, error) {
+	if r == nil || attempt == 0 {
+		return 0, nil
+	}
+
+	backoff, err := parseBackoff(r.Backoff)
+	if err != nil {
+		return 0, fmt.Errorf("invalid backoff - %s", err)
+	}
+	maxBackoff, err := parseBackoff(r.MaxBackoff)
+	if err != nil {
+		return 0, fmt.Errorf("invalid maxBackoff - %s", err)
+	}
+
+	factor := r.BackoffFactor
+	if factor == 0 {
+		factor = 1
+	}
+
+	// clamp before converting, since float64(math.MaxInt64) rounds up to 2^63 and overflows
+	delay := float64(backoff) * math.Pow(factor, float64(attempt-1))
+	if maxBackoff > 0 && delay >= float64(maxBackoff) {
+		return maxBackoff, nil
+	}
+	if delay >= float64(math.MaxInt64) {
+		return time.Duration(math.MaxInt64), nil
+	}
+	return time.Duration(delay), nil
+}
+
+func parseBackoff(backoff string) (time.Duration, error) {
+	if backoff == "" {
+		return 0, nil
+	}
+	result, err := time.ParseDuration(backoff)
+	if err != nil {
+		return 0, err
+	}
+	if result < 0 {
+		return 0, fmt.Errorf("duration must not be negative but is %s", backoff)
+	}
+	return result, nil
+}
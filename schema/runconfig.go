@@ -2,67 +2,115 @@ package schema
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/google/shlex"
 	"github.com/reeveci/reeve-lib/replacements"
+	"github.com/reeveci/reeve-lib/transforms"
 )
 
 type RunConfig struct {
-	Task    string     `json:"task" yaml:"task"`
-	Command RawCommand `json:"command" yaml:"command"`
-	Input   RawParam   `json:"input" yaml:"input"`
-	Mounts  []RawParam `json:"mounts" yaml:"mounts"`
+	Task    string  `json:"task" yaml:"task"`
+	Command Command `json:"command" yaml:"command"`
+	Input   Param   `json:"input" yaml:"input"`
+	Mounts  []Param `json:"mounts" yaml:"mounts"`
 	// Deprecated: Use Mounts instead
-	Directory RawParam            `json:"directory" yaml:"directory"`
-	User      RawParam            `json:"user" yaml:"user"`
-	Params    map[string]RawParam `json:"params" yaml:"params"`
+	Directory Param            `json:"directory" yaml:"directory"`
+	User      Param            `json:"user" yaml:"user"`
+	Params    map[string]Param `json:"params" yaml:"params"`
 }
 
+// GetEnv returns all env keys referenced by the config, including those interpolated into literals.
 func (config RunConfig) GetEnv() []string {
-	keys := make([]string, 0, 4+len(config.Params))
-
-	if key, ok := getEnv(config.Command); ok {
-		keys = append(keys, key)
-	}
-	if key, ok := getEnv(config.Input); ok {
+	required, optional := config.envKeys()
+	keys := make([]string, 0, len(required)+len(optional))
+	for key := range required {
 		keys = append(keys, key)
 	}
-	for _, v := range config.Mounts {
-		if key, ok := getEnv(v); ok {
+	for key := range optional {
+		if !required[key] {
 			keys = append(keys, key)
 		}
 	}
-	if key, ok := getEnv(config.Directory); ok {
-		keys = append(keys, key)
-	}
-	if key, ok := getEnv(config.User); ok {
-		keys = append(keys, key)
-	}
-	for _, v := range config.Params {
-		if key, ok := getEnv(v); ok {
+	return keys
+}
+
+// GetOptionalEnv returns all env keys which are only referenced by params with a fallback or default.
+func (config RunConfig) GetOptionalEnv() []string {
+	required, optional := config.envKeys()
+	keys := make([]string, 0, len(optional))
+	for key := range optional {
+		if !required[key] {
 			keys = append(keys, key)
 		}
 	}
-
 	return keys
 }
 
-func getEnv(param RawParam) (string, bool) {
-	switch value := param.(type) {
-	case map[string]any:
-		if envKey, ok := value["env"].(string); ok && envKey != "" {
-			return envKey, true
+func (config RunConfig) envKeys() (required, optional map[string]bool) {
+	required = make(map[string]bool)
+	optional = make(map[string]bool)
+	for _, rawParam := range config.rawParams() {
+		collectEnv(rawParam, false, required, optional)
+	}
+	return
+}
+
+func collectEnv(rawParam RawParam, isOptional bool, required, optional map[string]bool) {
+	for _, param := range references(rawParam) {
+		var fallback []Param
+		switch value := param.(type) {
+		case EnvParam:
+			fallback = value.Fallback
+			if value.Env != "" {
+				if isOptional || len(value.Fallback) > 0 || value.Default != nil {
+					optional[value.Env] = true
+				} else {
+					required[value.Env] = true
+				}
+			}
+
+		case VarParam:
+			fallback = value.Fallback
 		}
-	case EnvParam:
-		if value.Env != "" {
-			return value.Env, true
+
+		for _, fallbackParam := range fallback {
+			collectEnv(fallbackParam, true, required, optional)
 		}
 	}
-	return "", false
 }
 
-func (config RunConfig) Resolve(env map[string]Env, vars map[string]Var) (result ResolvedRunConfig, unresolvedEnv, unresolvedVars []string, err error) {
-	resolver := resolver{Env: env, Vars: vars}
+// GetOutputs returns all step outputs referenced by the config.
+func (config RunConfig) GetOutputs() []OutputParam {
+	outputs := make([]OutputParam, 0)
+	for _, rawParam := range config.rawParams() {
+		for _, param := range references(rawParam) {
+			if value, ok := param.(OutputParam); ok {
+				outputs = append(outputs, value)
+			}
+		}
+	}
+	return outputs
+}
+
+func (config RunConfig) rawParams() []RawParam {
+	result := make([]RawParam, 0, 4+len(config.Mounts)+len(config.Params))
+	result = append(result, config.Command, config.Input)
+	for _, mount := range config.Mounts {
+		result = append(result, mount)
+	}
+	result = append(result, config.Directory, config.User)
+	for _, param := range config.Params {
+		result = append(result, param)
+	}
+	return result
+}
+
+// Resolve resolves all params of the config.
+// Outputs contains the outputs of all completed steps by step name.
+// Unresolved outputs are reported as keys created by OutputKey.
+func (config RunConfig) Resolve(env map[string]Env, vars map[string]Var, outputs map[string]Outputs) (result ResolvedRunConfig, unresolvedEnv, unresolvedVars, unresolvedOutputs []string, err error) {
+	resolver := resolver{Env: env, Vars: vars, Outputs: outputs}
 	return resolver.Resolve(config)
 }
 
@@ -77,15 +125,17 @@ type ResolvedRunConfig struct {
 }
 
 type resolver struct {
-	Env  map[string]Env
-	Vars map[string]Var
+	Env     map[string]Env
+	Vars    map[string]Var
+	Outputs map[string]Outputs
 
-	unresolvedEnv, unresolvedVars []string
+	unresolvedEnv, unresolvedVars, unresolvedOutputs []string
 }
 
-func (r *resolver) Resolve(config RunConfig) (result ResolvedRunConfig, unresolvedEnv, unresolvedVars []string, err error) {
+func (r *resolver) Resolve(config RunConfig) (result ResolvedRunConfig, unresolvedEnv, unresolvedVars, unresolvedOutputs []string, err error) {
 	r.unresolvedEnv = make([]string, 0, 4+len(config.Params))
 	r.unresolvedVars = make([]string, 0, 4+len(config.Params))
+	r.unresolvedOutputs = make([]string, 0)
 
 	result.Mounts = make([]string, 0, len(config.Mounts))
 	result.Params = make(map[string]string, len(config.Params))
@@ -140,28 +190,31 @@ func (r *resolver) Resolve(config RunConfig) (result ResolvedRunConfig, unresolv
 		result.Params[k] = value
 	}
 
-	return result, r.unresolvedEnv, r.unresolvedVars, nil
+	return result, r.unresolvedEnv, r.unresolvedVars, r.unresolvedOutputs, nil
 }
 
-func (r *resolver) resolveCommand(command RawCommand) (result []string, found bool, err error) {
-	switch value := command.(type) {
-	case []string:
-		return value, true, nil
+func (r *resolver) resolveCommand(rawCommand RawCommand) (result []string, found bool, err error) {
+	command, err := parseCommand(rawCommand)
+	if err != nil {
+		return
+	}
 
+	var args [][]RawParam
+	switch value := command.(type) {
 	case LiteralCommand:
-		return value, true, nil
-
-	case []any:
-		args := make([]string, len(value))
-		for i, rawArg := range value {
-			var ok bool
-			args[i], ok = rawArg.(string)
-			if !ok {
-				err = fmt.Errorf("command may only contain strings but contains %T (%v)", rawArg, rawArg)
+		args = make([][]RawParam, len(value))
+		for i, arg := range value {
+			if args[i], err = parseInterpolation(arg); err != nil {
+				err = fmt.Errorf("invalid argument at index %v - %s", i, err)
 				return
 			}
 		}
-		return args, true, nil
+
+	case LiteralParam:
+		// split before resolving, so that resolved values are never split
+		if args, err = splitCommand(string(value)); err != nil {
+			return
+		}
 
 	default:
 		var resolvedCommand string
@@ -171,95 +224,292 @@ func (r *resolver) resolveCommand(command RawCommand) (result []string, found bo
 		result, err = shlex.Split(resolvedCommand)
 		return
 	}
+
+	result = make([]string, len(args))
+	found = true
+	for i, arg := range args {
+		var argFound bool
+		result[i], argFound, err = r.join(arg)
+		if err != nil {
+			err = fmt.Errorf("invalid argument at index %v - %s", i, err)
+			return
+		}
+		found = found && argFound
+	}
+	if !found {
+		result = nil
+	}
+	return
+}
+
+func (r *resolver) interpolate(value string) (result string, found bool, err error) {
+	segments, err := parseInterpolation(value)
+	if err != nil {
+		return
+	}
+	return r.join(segments)
+}
+
+// join resolves all segments and concatenates them, LiteralParam segments are not interpolated again.
+func (r *resolver) join(segments []RawParam) (result string, found bool, err error) {
+	found = true
+	builder := strings.Builder{}
+	for _, segment := range segments {
+		literal, ok := segment.(LiteralParam)
+		if ok {
+			builder.WriteString(string(literal))
+			continue
+		}
+
+		var resolved string
+		var segmentFound bool
+		resolved, segmentFound, err = r.resolve(segment)
+		if err != nil {
+			return
+		}
+		found = found && segmentFound
+		builder.WriteString(resolved)
+	}
+
+	if !found {
+		return "", false, nil
+	}
+	return builder.String(), true, nil
 }
 
-func (r *resolver) resolve(param RawParam) (result string, found bool, err error) {
+func (r *resolver) resolve(rawParam RawParam) (result string, found bool, err error) {
+	param, err := parseParam(rawParam)
+	if err != nil {
+		return
+	}
+
 	switch value := param.(type) {
 	case nil:
 		return "", true, nil
 
+	case LiteralParam:
+		return r.interpolate(string(value))
+
+	case EnvParam:
+		var envVal Env
+		envVal, found = r.Env[value.Env]
+		if !found {
+			return r.fallback(value.Fallback, value.Default, func() {
+				r.unresolvedEnv = append(r.unresolvedEnv, value.Env)
+			})
+		}
+		result, err = applyPipeline(envVal.Value, value.Replace, value.Transform)
+		return
+
+	case VarParam:
+		var varVal Var
+		varVal, found = r.Vars[value.Var]
+		if !found {
+			return r.fallback(value.Fallback, value.Default, func() {
+				r.unresolvedVars = append(r.unresolvedVars, value.Var)
+			})
+		}
+		result, err = applyPipeline(string(varVal), value.Replace, value.Transform)
+		return
+
+	case OutputParam:
+		var outputVal string
+		outputVal, found = r.Outputs[value.Step][value.Output]
+		if !found {
+			r.unresolvedOutputs = append(r.unresolvedOutputs, OutputKey(value.Step, value.Output))
+			return
+		}
+		result, err = applyPipeline(outputVal, value.Replace, value.Transform)
+		return
+
+	default:
+		err = fmt.Errorf("unexpected value %v of type %T", value, value)
+		return
+	}
+}
+
+// fallback resolves the first available fallback or the default.
+// If none is available, unresolved is called in addition to the unresolved fallbacks being reported.
+func (r *resolver) fallback(fallback []Param, defaultValue *string, unresolved func()) (result string, found bool, err error) {
+	env, vars, outputs := len(r.unresolvedEnv), len(r.unresolvedVars), len(r.unresolvedOutputs)
+	reset := func() {
+		r.unresolvedEnv = r.unresolvedEnv[:env]
+		r.unresolvedVars = r.unresolvedVars[:vars]
+		r.unresolvedOutputs = r.unresolvedOutputs[:outputs]
+	}
+
+	for i, param := range fallback {
+		result, found, err = r.resolve(param)
+		if err != nil {
+			err = fmt.Errorf("invalid fallback at index %v - %s", i, err)
+			return
+		}
+		if found {
+			reset()
+			return
+		}
+	}
+
+	if defaultValue != nil {
+		reset()
+		return *defaultValue, true, nil
+	}
+
+	unresolved()
+	return "", false, nil
+}
+
+// parseParam converts all accepted param shapes to nil, LiteralParam, EnvParam, VarParam or OutputParam.
+func parseParam(param RawParam) (RawParam, error) {
+	switch value := param.(type) {
+	case nil:
+		return nil, nil
+
+	case Param:
+		return parseParam(value.Value)
+
 	case string:
-		return value, true, nil
+		return LiteralParam(value), nil
 
-	case LiteralParam:
-		return string(value), true, nil
+	case LiteralParam, EnvParam, VarParam, OutputParam:
+		return value, nil
 
 	case map[string]any:
+		replace, err := parseStrings("replace", value["replace"])
+		if err != nil {
+			return nil, err
+		}
+		transform, err := parseStrings("transform", value["transform"])
+		if err != nil {
+			return nil, err
+		}
+		fallback, err := parseFallback(value["fallback"])
+		if err != nil {
+			return nil, err
+		}
+		var defaultValue *string
+		if rawDefault := value["default"]; rawDefault != nil {
+			defaultString, ok := rawDefault.(string)
+			if !ok {
+				return nil, fmt.Errorf("default must be a string but is %T (%v)", rawDefault, rawDefault)
+			}
+			defaultValue = &defaultString
+		}
+
 		if rawEnv := value["env"]; rawEnv != nil {
 			envKey, ok := rawEnv.(string)
 			if !ok {
-				err = fmt.Errorf("env must be a string but is %T (%v)", rawEnv, rawEnv)
-				return
-			}
-			var envVal Env
-			envVal, found = r.Env[envKey]
-			if !found {
-				r.unresolvedEnv = append(r.unresolvedEnv, envKey)
-				return
+				return nil, fmt.Errorf("env must be a string but is %T (%v)", rawEnv, rawEnv)
 			}
-			rawExpressions, _ := value["replace"].([]any)
-			expressions := make([]string, len(rawExpressions))
-			for i, rawReplace := range rawExpressions {
-				expressions[i], ok = rawReplace.(string)
-				if !ok {
-					err = fmt.Errorf("replace may only contain strings but contains %T (%v)", rawReplace, rawReplace)
-					return
-				}
-			}
-			result, err = replacements.Apply(envVal.Value, expressions)
-			return
+			return EnvParam{Env: envKey, Replace: replace, Transform: transform, Fallback: fallback, Default: defaultValue}, nil
 		}
 
 		if rawVar := value["var"]; rawVar != nil {
-			varKey, ok := value["var"].(string)
+			varKey, ok := rawVar.(string)
 			if !ok {
-				err = fmt.Errorf("var must be a string but is %T (%v)", rawVar, rawVar)
-				return
+				return nil, fmt.Errorf("var must be a string but is %T (%v)", rawVar, rawVar)
 			}
-			var varVal Var
-			varVal, found = r.Vars[varKey]
-			if !found {
-				r.unresolvedVars = append(r.unresolvedVars, varKey)
-				return
+			return VarParam{Var: varKey, Replace: replace, Transform: transform, Fallback: fallback, Default: defaultValue}, nil
+		}
+
+		if rawOutput := value["output"]; rawOutput != nil {
+			output, ok := rawOutput.(string)
+			if !ok {
+				return nil, fmt.Errorf("output must be a string but is %T (%v)", rawOutput, rawOutput)
 			}
-			rawExpressions, _ := value["replace"].([]any)
-			expressions := make([]string, len(rawExpressions))
-			for i, rawReplace := range rawExpressions {
-				expressions[i], ok = rawReplace.(string)
-				if !ok {
-					err = fmt.Errorf("replace may only contain strings but contains %T (%v)", rawReplace, rawReplace)
-					return
-				}
+			rawStep := value["step"]
+			step, ok := rawStep.(string)
+			if !ok {
+				return nil, fmt.Errorf("step must be a string but is %T (%v)", rawStep, rawStep)
 			}
-			result, err = replacements.Apply(string(varVal), expressions)
-			return
+			return OutputParam{Step: step, Output: output, Replace: replace, Transform: transform}, nil
 		}
 
-		err = fmt.Errorf("unexpected value %v", value)
-		return
+		return nil, fmt.Errorf("unexpected value %v", value)
 
-	case EnvParam:
-		var envVal Env
-		envVal, found = r.Env[value.Env]
-		if !found {
-			r.unresolvedEnv = append(r.unresolvedEnv, value.Env)
-			return
+	default:
+		return nil, fmt.Errorf("unexpected value %v of type %T", value, value)
+	}
+}
+
+// parseCommand converts all accepted command shapes to LiteralCommand or a param as returned by parseParam.
+func parseCommand(command RawCommand) (RawCommand, error) {
+	switch value := command.(type) {
+	case []string:
+		return LiteralCommand(value), nil
+
+	case LiteralCommand:
+		return value, nil
+
+	case Command:
+		return parseCommand(value.Value)
+
+	case []any:
+		args := make(LiteralCommand, len(value))
+		for i, rawArg := range value {
+			var ok bool
+			args[i], ok = rawArg.(string)
+			if !ok {
+				return nil, fmt.Errorf("command may only contain strings but contains %T (%v)", rawArg, rawArg)
+			}
 		}
-		result, err = replacements.Apply(envVal.Value, value.Replace)
-		return
+		return args, nil
 
-	case VarParam:
-		var varVal Var
-		varVal, found = r.Vars[value.Var]
-		if !found {
-			r.unresolvedVars = append(r.unresolvedVars, value.Var)
-			return
+	default:
+		return parseParam(command)
+	}
+}
+
+func parseFallback(rawFallback any) ([]Param, error) {
+	switch value := rawFallback.(type) {
+	case nil:
+		return nil, nil
+
+	case []Param:
+		return value, nil
+
+	case []any:
+		fallback := make([]Param, len(value))
+		for i, rawParam := range value {
+			param, err := NewParam(rawParam)
+			if err != nil {
+				return nil, fmt.Errorf("invalid fallback at index %v - %s", i, err)
+			}
+			fallback[i] = param
 		}
-		result, err = replacements.Apply(string(varVal), value.Replace)
-		return
+		return fallback, nil
 
 	default:
-		err = fmt.Errorf("unexpected value %v of type %T", value, value)
-		return
+		return nil, fmt.Errorf("fallback must be a list but is %T (%v)", rawFallback, rawFallback)
+	}
+}
+
+func parseStrings(name string, rawStrings any) ([]string, error) {
+	switch value := rawStrings.(type) {
+	case []string:
+		return value, nil
+
+	case []any:
+		result := make([]string, len(value))
+		for i, rawString := range value {
+			var ok bool
+			result[i], ok = rawString.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s may only contain strings but contains %T (%v)", name, rawString, rawString)
+			}
+		}
+		return result, nil
+
+	default:
+		return nil, nil
+	}
+}
+
+// applyPipeline applies the replacements followed by the transforms.
+func applyPipeline(value string, replace, transform []string) (string, error) {
+	result, err := replacements.Apply(value, replace)
+	if err != nil {
+		return "", err
 	}
+	return transforms.Apply(result, transform)
 }
@@ -0,0 +1,46 @@
+package schema
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestResolveStringCommandReferences(t *testing.T) {
+	env := map[string]Env{
+		"VERSION": {Value: "1.2.3"},
+		"IMAGE":   {Value: "my image"},
+	}
+
+	tests := []struct {
+		command  string
+		expected []string
+	}{
+		{`echo ${env:VERSION|/\./-/}`, []string{"echo", "1-2-3"}},
+		{`echo ${env:IMAGE|/ /_/}`, []string{"echo", "my_image"}},
+		{`echo "v${env:VERSION|/\./ /}" done`, []string{"echo", "v1 2 3", "done"}},
+		{`echo ${env:IMAGE}`, []string{"echo", "my image"}},
+		{`echo '$${env:IMAGE}'`, []string{"echo", "${env:IMAGE}"}},
+	}
+
+	for _, test := range tests {
+		config := RunConfig{Command: Command{Value: LiteralParam(test.command)}}
+
+		v := &validator{}
+		v.validateRunConfig("", config)
+		if len(v.errors) > 0 {
+			t.Errorf("%s: unexpected validation errors - %s", test.command, v.errors)
+		}
+
+		result, unresolvedEnv, _, _, err := config.Resolve(env, nil, nil)
+		if err != nil {
+			t.Errorf("%s: unexpected error - %s", test.command, err)
+			continue
+		}
+		if len(unresolvedEnv) > 0 {
+			t.Errorf("%s: unexpected unresolved env %v", test.command, unresolvedEnv)
+		}
+		if !slices.Equal(result.Command, test.expected) {
+			t.Errorf("%s: expected %q, got %q", test.command, test.expected, result.Command)
+		}
+	}
+}
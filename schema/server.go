@@ -1,7 +1,9 @@
 package schema
 
 import (
+	"fmt"
 	"io"
+	"time"
 )
 
 const BROADCAST_MESSAGE = "*"
@@ -14,6 +16,81 @@ const STATUS_RUNNING Status = "running"
 const STATUS_SUCCESS Status = "success"
 const STATUS_FAILED Status = "failed"
 const STATUS_TIMEOUT Status = "timeout"
+const STATUS_CANCELLED Status = "cancelled"
+const STATUS_SKIPPED Status = "skipped"
+
+// StatusTransitions lists the statuses each status may move to.
+// New pipelines start as STATUS_ENQUEUED. Finished statuses are final.
+var StatusTransitions = map[Status][]Status{
+	STATUS_ENQUEUED: {STATUS_WAITING, STATUS_RUNNING, STATUS_FAILED, STATUS_TIMEOUT, STATUS_CANCELLED, STATUS_SKIPPED},
+	// a worker received the pipeline but did not acknowledge it yet, so it may be enqueued again
+	STATUS_WAITING: {STATUS_ENQUEUED, STATUS_RUNNING, STATUS_FAILED, STATUS_TIMEOUT, STATUS_CANCELLED},
+	// the worker running the pipeline may be lost, so it may be enqueued again
+	STATUS_RUNNING:   {STATUS_ENQUEUED, STATUS_SUCCESS, STATUS_FAILED, STATUS_TIMEOUT, STATUS_CANCELLED},
+	STATUS_SUCCESS:   {},
+	STATUS_FAILED:    {},
+	STATUS_TIMEOUT:   {},
+	STATUS_CANCELLED: {},
+	STATUS_SKIPPED:   {},
+}
+
+func (s Status) Valid() bool {
+	_, ok := StatusTransitions[s]
+	return ok
+}
+
+func (s Status) CanTransition(to Status) bool {
+	for _, next := range StatusTransitions[s] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+func ValidateStatusTransition(from, to Status) error {
+	if !from.Valid() {
+		return fmt.Errorf(`invalid status "%s"`, from)
+	}
+	if !to.Valid() {
+		return fmt.Errorf(`invalid status "%s"`, to)
+	}
+	if !from.CanTransition(to) {
+		return fmt.Errorf(`invalid status transition from "%s" to "%s"`, from, to)
+	}
+	return nil
+}
+
+func (s Status) Running() bool {
+	switch s {
+	case STATUS_WAITING, STATUS_RUNNING:
+		return true
+
+	default:
+		return false
+	}
+}
+
+func (s Status) Finished() bool {
+	switch s {
+	case STATUS_SUCCESS, STATUS_FAILED, STATUS_TIMEOUT, STATUS_CANCELLED, STATUS_SKIPPED:
+		return true
+
+	default:
+		return false
+	}
+}
+
+// Failed reports whether the pipeline did not succeed on its own, in contrast to being cancelled or skipped.
+func (s Status) Failed() bool {
+	switch s {
+	case STATUS_FAILED, STATUS_TIMEOUT:
+		return true
+
+	default:
+		return false
+	}
+}
 
 type Error string
 
@@ -22,6 +99,7 @@ func (err Error) Error() string {
 }
 
 const ERROR_UNAVAILABLE = Error("not available")
+const ERROR_STALE_CONTRACT = Error("stale contract")
 
 const EVENT_STARTUP_COMPLETE = "startup complete"
 
@@ -59,6 +137,8 @@ type PipelineStatus struct {
 	Pipeline    Pipeline
 	WorkerGroup string
 	ActivityID  string
+	// Time the pipeline was enqueued, determines the order of pipelines in concurrency groups
+	Enqueued time.Time
 
 	Status Status
 	Logs   LogReaderProvider
@@ -66,23 +146,20 @@ type PipelineStatus struct {
 }
 
 func (s *PipelineStatus) Running() bool {
-	switch s.Status {
-	case STATUS_WAITING, STATUS_RUNNING:
-		return true
-
-	default:
-		return false
-	}
+	return s.Status.Running()
 }
 
 func (s *PipelineStatus) Finished() bool {
-	switch s.Status {
-	case STATUS_SUCCESS, STATUS_FAILED, STATUS_TIMEOUT:
-		return true
+	return s.Status.Finished()
+}
 
-	default:
-		return false
+// Transition changes the status if the transition is valid.
+func (s *PipelineStatus) Transition(to Status) error {
+	if err := ValidateStatusTransition(s.Status, to); err != nil {
+		return err
 	}
+	s.Status = to
+	return nil
 }
 
 type LogReader interface {
@@ -98,16 +175,36 @@ type LogReaderProvider interface {
 	io.Closer
 }
 
+type WorkerQueueRequest struct {
+	// Labels the worker advertises, e.g. arch, os or custom tags
+	Labels map[string]string `json:"labels"`
+}
+
 type WorkerQueueResponse struct {
 	Contract string   `json:"contract"`
 	Activity string   `json:"activity"`
 	Pipeline Pipeline `json:"pipeline"`
+
+	// The contract expires at this time unless it is renewed by a heartbeat
+	LeaseExpires time.Time `json:"leaseExpires"`
+	// Interval in which the worker should send heartbeats
+	HeartbeatInterval time.Duration `json:"heartbeatInterval"`
 }
 
 type WorkerAckRequest struct {
 	Contract string `json:"contract"`
 }
 
+type WorkerHeartbeatRequest struct {
+	Contract string `json:"contract"`
+}
+
+type WorkerHeartbeatResponse struct {
+	LeaseExpires time.Time `json:"leaseExpires"`
+	// The pipeline was cancelled and the worker should stop running it
+	Cancel bool `json:"cancel"`
+}
+
 type WorkerLogsPositionResponse struct {
 	Position int64 `json:"position"`
 }
@@ -116,4 +213,46 @@ type PipelineResult struct {
 	Success  bool   `json:"success"`
 	ExitCode int    `json:"exitCode"`
 	Error    string `json:"error"`
+	// Number of attempts of the step which determined the result
+	Attempts      uint          `json:"attempts"`
+	FailureReason FailureReason `json:"failureReason"`
+
+	Started  time.Time    `json:"started"`
+	Finished time.Time    `json:"finished"`
+	Steps    []StepResult `json:"steps"`
+}
+
+// FailedStep returns the result of the step which failed the pipeline, if any.
+func (r PipelineResult) FailedStep() (StepResult, bool) {
+	for _, step := range r.Steps {
+		if step.Status.Failed() && !step.IgnoredFailure {
+			return step, true
+		}
+	}
+	return StepResult{}, false
+}
+
+type StepResult struct {
+	Name     string `json:"name"`
+	Status   Status `json:"status"`
+	ExitCode int    `json:"exitCode"`
+	Error    string `json:"error"`
+
+	Attempts      uint          `json:"attempts"`
+	FailureReason FailureReason `json:"failureReason"`
+	// The step failed, but the pipeline continued because the step has IgnoreFailure set
+	IgnoredFailure bool `json:"ignoredFailure"`
+	// Why the step was skipped, e.g. which condition was not met
+	SkipReason string `json:"skipReason"`
+
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+}
+
+// Duration returns the time between start and end of the step, or 0 if it did not finish.
+func (r StepResult) Duration() time.Duration {
+	if r.Started.IsZero() || r.Finished.IsZero() {
+		return 0
+	}
+	return r.Finished.Sub(r.Started)
 }
@@ -6,14 +6,26 @@ const VAR_PREFIX = "var "
 const DEFAULT_WORKER_GROUP = "default"
 const DEFAULT_STAGE = "default"
 
+const MATRIX_VAR_PREFIX = "matrix."
+
 type PipelineDefinition struct {
+	APIVersion  string               `json:"apiVersion" yaml:"apiVersion"`
 	Name        string               `json:"name" yaml:"name"`
 	Headline    string               `json:"headline" yaml:"headline"`
 	Description string               `json:"description" yaml:"description"`
+	Extends     []string             `json:"extends" yaml:"extends"`
 	When        map[string]Condition `json:"when" yaml:"when"`
+	Selector    []string             `json:"selector" yaml:"selector"`
+	Concurrency *Concurrency         `json:"concurrency" yaml:"concurrency"`
 	Steps       []Step               `json:"steps" yaml:"steps"`
 }
 
+type PipelineTemplate struct {
+	PipelineDefinition `yaml:",inline"`
+
+	Setup *Setup `json:"setup" yaml:"setup"`
+}
+
 type Pipeline struct {
 	PipelineDefinition `yaml:",inline"`
 
@@ -36,6 +48,9 @@ type Var string
 
 type Setup struct {
 	RunConfig `yaml:",inline"`
+
+	Timeout string       `json:"timeout" yaml:"timeout"`
+	Retry   *RetryPolicy `json:"retry" yaml:"retry"`
 }
 
 type Step struct {
@@ -43,8 +58,21 @@ type Step struct {
 
 	Name          string               `json:"name" yaml:"name"`
 	Stage         string               `json:"stage" yaml:"stage"`
+	Needs         []string             `json:"needs" yaml:"needs"`
 	When          map[string]Condition `json:"when" yaml:"when"`
+	Selector      []string             `json:"selector" yaml:"selector"`
 	IgnoreFailure bool                 `json:"ignoreFailure" yaml:"ignoreFailure"`
+	Timeout       string               `json:"timeout" yaml:"timeout"`
+	Retry         *RetryPolicy         `json:"retry" yaml:"retry"`
+	Outputs       []string             `json:"outputs" yaml:"outputs"`
+	Matrix        *Matrix              `json:"matrix" yaml:"matrix"`
+	Vars          map[string]Var       `json:"vars" yaml:"vars"`
+}
+
+type Matrix struct {
+	Axes    map[string][]string `json:"axes" yaml:"axes"`
+	Include []map[string]string `json:"include" yaml:"include"`
+	Exclude []map[string]string `json:"exclude" yaml:"exclude"`
 }
 
 type RawParam any
@@ -52,10 +80,29 @@ type LiteralParam string
 type EnvParam struct {
 	Env     string   `json:"env" yaml:"env"`
 	Replace []string `json:"replace" yaml:"replace"`
+	// Built-in transforms applied after the replacements, e.g. "lower" or "truncate:8"
+	Transform []string `json:"transform" yaml:"transform"`
+	// Params tried in order if the env is not available
+	Fallback []Param `json:"fallback" yaml:"fallback"`
+	// Literal used if neither the env nor any fallback is available
+	Default *string `json:"default" yaml:"default"`
 }
 type VarParam struct {
 	Var     string   `json:"var" yaml:"var"`
 	Replace []string `json:"replace" yaml:"replace"`
+	// Like EnvParam.Transform
+	Transform []string `json:"transform" yaml:"transform"`
+	// Params tried in order if the var is not available
+	Fallback []Param `json:"fallback" yaml:"fallback"`
+	// Literal used if neither the var nor any fallback is available
+	Default *string `json:"default" yaml:"default"`
+}
+type OutputParam struct {
+	Step    string   `json:"step" yaml:"step"`
+	Output  string   `json:"output" yaml:"output"`
+	Replace []string `json:"replace" yaml:"replace"`
+	// Like EnvParam.Transform
+	Transform []string `json:"transform" yaml:"transform"`
 }
 
 type RawCommand any
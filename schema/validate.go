@@ -0,0 +1,387 @@
+package schema
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/reeveci/reeve-lib/interpolation"
+	"github.com/reeveci/reeve-lib/labels"
+	"github.com/reeveci/reeve-lib/replacements"
+	"github.com/reeveci/reeve-lib/transforms"
+)
+
+type ValidationError struct {
+	// Location of the problem, e.g. "steps[3].when.branch.match[0]"
+	Path    string
+	Message string
+}
+
+func (err ValidationError) Error() string {
+	if err.Path == "" {
+		return err.Message
+	}
+	return err.Path + ": " + err.Message
+}
+
+type ValidationErrors []ValidationError
+
+func (errs ValidationErrors) Error() string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Err returns nil if there are no errors, which avoids returning a non-nil error interface holding an empty slice.
+func (errs ValidationErrors) Err() error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// Validate reports every problem found in the pipeline definition.
+func (definition PipelineDefinition) Validate() ValidationErrors {
+	v := &validator{}
+	v.validateDefinition(definition)
+	return v.errors
+}
+
+// Validate reports every problem found in the pipeline, including its setup.
+func (pipeline Pipeline) Validate() ValidationErrors {
+	v := &validator{}
+	v.validateDefinition(pipeline.PipelineDefinition)
+	v.step = -1
+	v.validateRunConfig("setup", pipeline.Setup.RunConfig)
+	v.validateExecution("setup", pipeline.Setup.Timeout, pipeline.Setup.Retry)
+	return v.errors
+}
+
+type validator struct {
+	errors ValidationErrors
+
+	// index of the step currently being validated, -1 for the setup
+	step       int
+	outputRefs []outputRef
+}
+
+type outputRef struct {
+	path  string
+	step  int
+	param OutputParam
+}
+
+func (v *validator) add(path, format string, a ...any) {
+	v.errors = append(v.errors, ValidationError{Path: path, Message: fmt.Sprintf(format, a...)})
+}
+
+func (v *validator) validateDefinition(definition PipelineDefinition) {
+	if !slices.Contains(APIVersions, definition.GetAPIVersion()) {
+		v.add("apiVersion", `unsupported apiVersion "%s"`, definition.APIVersion)
+	}
+	v.validateConditions("when", definition.When)
+	v.validateSelector("selector", definition.Selector)
+	if definition.Concurrency != nil {
+		v.validateConcurrency("concurrency", *definition.Concurrency)
+	}
+
+	names := make(map[string]int, len(definition.Steps))
+	for i, step := range definition.Steps {
+		path := IndexPath("steps", i)
+
+		if step.Name == "" {
+			v.add(KeyPath(path, "name"), "step name must not be empty")
+		} else if previous, ok := names[step.Name]; ok {
+			v.add(KeyPath(path, "name"), `duplicate step name "%s" (also used by %s)`, step.Name, IndexPath("steps", previous))
+		} else {
+			names[step.Name] = i
+		}
+
+		v.validateConditions(KeyPath(path, "when"), step.When)
+		v.validateSelector(KeyPath(path, "selector"), step.Selector)
+		v.step = i
+		v.validateRunConfig(path, step.RunConfig)
+		v.validateOutputs(KeyPath(path, "outputs"), step.Outputs)
+		v.validateExecution(path, step.Timeout, step.Retry)
+		if step.Matrix != nil {
+			v.validateMatrix(KeyPath(path, "matrix"), *step.Matrix)
+		}
+	}
+
+	graph := v.buildStepGraph(definition.Steps)
+	v.validateOutputRefs(graph)
+}
+
+func (v *validator) validateSelector(path string, selector []string) {
+	for i, expression := range selector {
+		if err := labels.Validate(expression); err != nil {
+			v.add(IndexPath(path, i), "%s", err)
+		}
+	}
+}
+
+func (v *validator) validateConcurrency(path string, concurrency Concurrency) {
+	if concurrency.Group == "" {
+		v.add(KeyPath(path, "group"), "concurrency group must not be empty")
+	} else if segments, err := interpolation.ParseSources(concurrency.Group, ConcurrencySources); err != nil {
+		v.add(KeyPath(path, "group"), "%s", err)
+	} else {
+		for _, segment := range segments {
+			v.validateReplace(KeyPath(path, "group"), segment.Replace)
+			v.validateTransform(KeyPath(path, "group"), segment.Transform)
+		}
+	}
+
+	if !slices.Contains(ConcurrencyPolicies, concurrency.GetPolicy()) {
+		v.add(KeyPath(path, "policy"), `unknown concurrency policy "%s"`, concurrency.Policy)
+	}
+}
+
+func (v *validator) validateOutputs(path string, outputs []string) {
+	seen := make(map[string]bool, len(outputs))
+	for i, output := range outputs {
+		if err := ValidateOutputName(output); err != nil {
+			v.add(IndexPath(path, i), "%s", err)
+		} else if seen[output] {
+			v.add(IndexPath(path, i), `duplicate output "%s"`, output)
+		}
+		seen[output] = true
+	}
+}
+
+func (v *validator) validateOutputRefs(graph *StepGraph) {
+	names := make(map[string]int, len(graph.Steps))
+	for i, step := range graph.Steps {
+		if _, ok := names[step.Name]; !ok && step.Name != "" {
+			names[step.Name] = i
+		}
+	}
+
+	for _, ref := range v.outputRefs {
+		source, ok := names[ref.param.Step]
+		if !ok {
+			v.add(KeyPath(ref.path, "step"), `unknown step "%s"`, ref.param.Step)
+			continue
+		}
+		if graph.Steps[source].Matrix != nil {
+			// the step runs once per combination, so there is no single value to use
+			v.add(KeyPath(ref.path, "step"), `outputs of matrix step "%s" cannot be referenced`, ref.param.Step)
+			continue
+		}
+		if !slices.Contains(graph.Steps[source].Outputs, ref.param.Output) {
+			v.add(KeyPath(ref.path, "output"), `step "%s" does not declare output "%s"`, ref.param.Step, ref.param.Output)
+		}
+		if !graph.dependsOn(ref.step, source) {
+			v.add(KeyPath(ref.path, "step"), `step "%s" must be a dependency to use its outputs`, ref.param.Step)
+		}
+	}
+}
+
+func (v *validator) validateConditions(path string, conditions map[string]Condition) {
+	for _, key := range slices.Sorted(maps.Keys(conditions)) {
+		condition := conditions[key]
+		conditionPath := KeyPath(path, key)
+
+		for i, expression := range condition.Match {
+			if _, err := regexp.Compile(expression); err != nil {
+				v.add(IndexPath(KeyPath(conditionPath, "match"), i), "invalid regexp - %s", err)
+			}
+		}
+		for i, expression := range condition.Mismatch {
+			if _, err := regexp.Compile(expression); err != nil {
+				v.add(IndexPath(KeyPath(conditionPath, "mismatch"), i), "invalid regexp - %s", err)
+			}
+		}
+	}
+}
+
+func (v *validator) validateExecution(path string, timeout string, retry *RetryPolicy) {
+	if _, err := parseTimeout(timeout); err != nil {
+		v.add(KeyPath(path, "timeout"), "%s", err)
+	}
+	if err := retry.Validate(); err != nil {
+		v.add(KeyPath(path, "retry"), "%s", err)
+	}
+}
+
+func (v *validator) validateMatrix(path string, matrix Matrix) {
+	if _, err := matrix.Combinations(); err != nil {
+		v.add(path, "%s", err)
+	}
+
+	for i, exclude := range matrix.Exclude {
+		for _, key := range slices.Sorted(maps.Keys(exclude)) {
+			if _, ok := matrix.Axes[key]; !ok {
+				v.add(KeyPath(IndexPath(KeyPath(path, "exclude"), i), key), `unknown axis "%s"`, key)
+			}
+		}
+	}
+}
+
+func (v *validator) validateRunConfig(path string, config RunConfig) {
+	v.validateCommand(KeyPath(path, "command"), config.Command)
+	v.validateParam(KeyPath(path, "input"), config.Input)
+	for i, mount := range config.Mounts {
+		v.validateParam(IndexPath(KeyPath(path, "mounts"), i), mount)
+	}
+	if !config.Directory.IsZero() {
+		v.add(KeyPath(path, "directory"), "directory is deprecated - use mounts instead")
+		v.validateParam(KeyPath(path, "directory"), config.Directory)
+	}
+	v.validateParam(KeyPath(path, "user"), config.User)
+	for _, key := range slices.Sorted(maps.Keys(config.Params)) {
+		param := config.Params[key]
+		if key == "" {
+			v.add(KeyPath(path, "params"), "param name must not be empty")
+			continue
+		}
+		v.validateParam(KeyPath(KeyPath(path, "params"), key), param)
+	}
+}
+
+func (v *validator) validateCommand(path string, rawCommand RawCommand) {
+	if value, ok := rawCommand.(Command); ok {
+		rawCommand = value.Value
+	}
+	command, err := parseCommand(rawCommand)
+	if err != nil {
+		v.add(path, "%s", err)
+		return
+	}
+
+	switch value := command.(type) {
+	case LiteralCommand:
+		for i, arg := range value {
+			v.validateLiteral(IndexPath(path, i), arg)
+		}
+
+	case LiteralParam:
+		args, err := splitCommand(string(value))
+		if err != nil {
+			v.add(path, "invalid shell command - %s", err)
+			return
+		}
+		for _, arg := range args {
+			for _, segment := range arg {
+				if _, ok := segment.(LiteralParam); !ok {
+					v.validateParam(path, segment)
+				}
+			}
+		}
+
+	default:
+		v.validateParam(path, rawCommand)
+	}
+}
+
+func (v *validator) validateParam(path string, rawParam RawParam) {
+	if value, ok := rawParam.(Param); ok {
+		rawParam = value.Value
+	}
+	if value, ok := rawParam.(map[string]any); ok {
+		if _, err := paramType(value); err != nil {
+			v.add(path, "%s", err)
+			return
+		}
+	}
+
+	param, err := parseParam(rawParam)
+	if err != nil {
+		v.add(path, "%s", err)
+		return
+	}
+
+	switch value := param.(type) {
+	case LiteralParam:
+		v.validateLiteral(path, string(value))
+
+	case EnvParam:
+		if value.Env == "" {
+			v.add(KeyPath(path, "env"), "env key must not be empty")
+		}
+		v.validateReplace(KeyPath(path, "replace"), value.Replace)
+		v.validateTransform(KeyPath(path, "transform"), value.Transform)
+		v.validateFallback(KeyPath(path, "fallback"), value.Fallback)
+
+	case VarParam:
+		if value.Var == "" {
+			v.add(KeyPath(path, "var"), "var key must not be empty")
+		}
+		v.validateReplace(KeyPath(path, "replace"), value.Replace)
+		v.validateTransform(KeyPath(path, "transform"), value.Transform)
+		v.validateFallback(KeyPath(path, "fallback"), value.Fallback)
+
+	case OutputParam:
+		if v.step < 0 {
+			v.add(path, "step outputs are not available in the setup")
+		} else if value.Step != "" && value.Output != "" {
+			v.outputRefs = append(v.outputRefs, outputRef{path: path, step: v.step, param: value})
+		}
+		if value.Step == "" {
+			v.add(KeyPath(path, "step"), "step must not be empty")
+		}
+		if value.Output == "" {
+			v.add(KeyPath(path, "output"), "output must not be empty")
+		}
+		v.validateReplace(KeyPath(path, "replace"), value.Replace)
+		v.validateTransform(KeyPath(path, "transform"), value.Transform)
+	}
+}
+
+func (v *validator) validateLiteral(path string, value string) {
+	segments, err := parseInterpolation(value)
+	if err != nil {
+		v.add(path, "%s", err)
+		return
+	}
+	for _, segment := range segments {
+		if _, ok := segment.(LiteralParam); !ok {
+			v.validateParam(path, segment)
+		}
+	}
+}
+
+func (v *validator) validateFallback(path string, fallback []Param) {
+	for i, param := range fallback {
+		v.validateParam(IndexPath(path, i), param)
+	}
+}
+
+func (v *validator) validateTransform(path string, transform []string) {
+	for i, expression := range transform {
+		if err := transforms.Validate(expression); err != nil {
+			v.add(IndexPath(path, i), "%s", err)
+		}
+	}
+}
+
+func (v *validator) validateReplace(path string, expressions []string) {
+	for i, expression := range expressions {
+		if err := replacements.Validate(expression); err != nil {
+			v.add(IndexPath(path, i), "%s", err)
+		}
+	}
+}
+
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// KeyPath appends a map key or field name to a path, quoting keys which are not plain identifiers.
+func KeyPath(path, key string) string {
+	if !identifierPattern.MatchString(key) {
+		return path + "[" + strconv.Quote(key) + "]"
+	}
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// IndexPath appends a list index to a path.
+func IndexPath(path string, index int) string {
+	return path + "[" + strconv.Itoa(index) + "]"
+}
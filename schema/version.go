@@ -0,0 +1,19 @@
+package schema
+
+// Initial version, also assumed for definitions without apiVersion
+const API_VERSION_V1 = "v1"
+
+// Replaces RunConfig.Directory with Mounts
+const API_VERSION_V2 = "v2"
+
+const CURRENT_API_VERSION = API_VERSION_V2
+
+var APIVersions = []string{API_VERSION_V1, API_VERSION_V2}
+
+// GetAPIVersion returns the api version of the definition, defaulting to API_VERSION_V1.
+func (p PipelineDefinition) GetAPIVersion() string {
+	if p.APIVersion == "" {
+		return API_VERSION_V1
+	}
+	return p.APIVersion
+}
@@ -0,0 +1,313 @@
+package templates
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/reeveci/reeve-lib/schema"
+)
+
+// Document is a decoded JSON or YAML pipeline definition or template.
+// Unlike the schema types, it tells which keys are present, so explicit zero values can override inherited ones.
+type Document = map[string]any
+
+// Keys whose values are merged by key instead of being replaced
+var mapKeys = map[string]bool{"when": true, "params": true, "vars": true}
+
+// ApplyDocuments merges all templates the definition extends into the definition and returns the merged setup of these templates.
+//
+// Precedence rules:
+//   - templates are applied in the order they are listed in extends, later templates override earlier ones
+//   - a template extending other templates overrides them the same way
+//   - the definition overrides all templates
+//
+// Overriding works key by key:
+//   - keys which are present override the inherited value, even if the value is empty, false, 0 or null
+//   - keys which are not present are inherited
+//   - maps like when, params and vars are merged by key, null entries remove inherited entries,
+//     an empty map removes all inherited entries, conditions in when are merged key by key
+//   - setup is merged key by key
+//   - steps are merged by name, a step overrides the inherited step with the same name key by key,
+//     all other steps are appended in order
+func ApplyDocuments(definition Document, templates map[string]Document) (schema.PipelineDefinition, *schema.Setup, error) {
+	r := &resolver{templates: templates, resolved: make(map[string]Document)}
+
+	document, err := r.resolveExtends(definition, nil)
+	if err != nil {
+		return schema.PipelineDefinition{}, nil, err
+	}
+
+	data, err := json.Marshal(document)
+	if err != nil {
+		return schema.PipelineDefinition{}, nil, fmt.Errorf("error encoding merged pipeline - %s", err)
+	}
+	var result schema.PipelineTemplate
+	if err := json.Unmarshal(data, &result); err != nil {
+		return schema.PipelineDefinition{}, nil, fmt.Errorf("error decoding merged pipeline - %s", err)
+	}
+	return result.PipelineDefinition, result.Setup, nil
+}
+
+// Apply works like ApplyDocuments for decoded definitions.
+// Since these cannot tell unset fields from zero values, fields with zero values are always inherited.
+func Apply(definition schema.PipelineDefinition, templates map[string]schema.PipelineTemplate) (schema.PipelineDefinition, *schema.Setup, error) {
+	return apply(schema.PipelineTemplate{PipelineDefinition: definition}, templates)
+}
+
+// ApplyPipeline works like Apply, the setup of the pipeline overrides the setup of the templates.
+func ApplyPipeline(pipeline schema.Pipeline, templates map[string]schema.PipelineTemplate) (schema.Pipeline, error) {
+	definition, setup, err := apply(schema.PipelineTemplate{PipelineDefinition: pipeline.PipelineDefinition, Setup: &pipeline.Setup}, templates)
+	if err != nil {
+		return schema.Pipeline{}, err
+	}
+
+	pipeline.PipelineDefinition = definition
+	if setup != nil {
+		pipeline.Setup = *setup
+	}
+	return pipeline, nil
+}
+
+func apply(definition schema.PipelineTemplate, templates map[string]schema.PipelineTemplate) (schema.PipelineDefinition, *schema.Setup, error) {
+	documents := make(map[string]Document, len(templates))
+	for name, template := range templates {
+		document, err := toDocument(template)
+		if err != nil {
+			return schema.PipelineDefinition{}, nil, fmt.Errorf(`invalid template "%s" - %s`, name, err)
+		}
+		documents[name] = document
+	}
+
+	document, err := toDocument(definition)
+	if err != nil {
+		return schema.PipelineDefinition{}, nil, err
+	}
+
+	return ApplyDocuments(document, documents)
+}
+
+// toDocument encodes a template, leaving out all fields with zero values.
+func toDocument(template schema.PipelineTemplate) (Document, error) {
+	data, err := json.Marshal(template)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding pipeline - %s", err)
+	}
+	var document Document
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("error encoding pipeline - %s", err)
+	}
+	pruneZero(document)
+	return document, nil
+}
+
+// pruneZero removes zero values from a pipeline, template, setup or step, values like params are kept as they are.
+func pruneZero(document Document) {
+	for key, member := range document {
+		switch key {
+		case "setup":
+			if setup, ok := member.(map[string]any); ok {
+				pruneZero(setup)
+			}
+		case "steps":
+			for _, step := range asList(member) {
+				if step, ok := step.(map[string]any); ok {
+					pruneZero(step)
+				}
+			}
+		case "when":
+			if when, ok := member.(map[string]any); ok {
+				for _, condition := range when {
+					if condition, ok := condition.(map[string]any); ok {
+						pruneZero(condition)
+					}
+				}
+			}
+		}
+
+		if isZero(member) {
+			delete(document, key)
+		}
+	}
+}
+
+func isZero(value any) bool {
+	switch value := value.(type) {
+	case nil:
+		return true
+	case map[string]any:
+		return len(value) == 0
+	case []any:
+		return len(value) == 0
+	case string:
+		return value == ""
+	case bool:
+		return !value
+	case float64:
+		return value == 0
+	default:
+		return false
+	}
+}
+
+type resolver struct {
+	templates map[string]Document
+	resolved  map[string]Document
+}
+
+func (r *resolver) resolve(name string, chain []string) (Document, error) {
+	if result, ok := r.resolved[name]; ok {
+		return result, nil
+	}
+
+	for i, previous := range chain {
+		if previous == name {
+			return nil, fmt.Errorf("cyclic template %s", strings.Join(append(slices.Clone(chain[i:]), name), " -> "))
+		}
+	}
+
+	template, ok := r.templates[name]
+	if !ok {
+		if len(chain) == 0 {
+			return nil, fmt.Errorf(`unknown template "%s"`, name)
+		}
+		return nil, fmt.Errorf(`unknown template "%s" (extended by "%s")`, name, chain[len(chain)-1])
+	}
+
+	result, err := r.resolveExtends(template, append(slices.Clip(chain), name))
+	if err != nil {
+		return nil, err
+	}
+
+	r.resolved[name] = result
+	return result, nil
+}
+
+func (r *resolver) resolveExtends(template Document, chain []string) (Document, error) {
+	var extends []string
+	switch value := template["extends"].(type) {
+	case nil:
+	case []any:
+		for _, name := range value {
+			name, ok := name.(string)
+			if !ok {
+				return nil, fmt.Errorf("extends must be a list of template names")
+			}
+			extends = append(extends, name)
+		}
+	case []string:
+		extends = value
+	default:
+		return nil, fmt.Errorf("extends must be a list of template names")
+	}
+
+	base := make(Document)
+	for _, name := range extends {
+		parent, err := r.resolve(name, chain)
+		if err != nil {
+			return nil, err
+		}
+		base = mergeDocuments(base, parent)
+	}
+
+	template = maps.Clone(template)
+	delete(template, "extends")
+	return mergeDocuments(base, template), nil
+}
+
+// mergeDocuments merges a pipeline, template, setup or step key by key.
+func mergeDocuments(base, override Document) Document {
+	result := maps.Clone(base)
+	for key, value := range override {
+		switch {
+		case key == "steps":
+			result[key] = mergeSteps(asList(base[key]), asList(value))
+
+		case key == "setup":
+			baseSetup, baseOk := base[key].(map[string]any)
+			setup, ok := value.(map[string]any)
+			if baseOk && ok {
+				result[key] = mergeDocuments(baseSetup, setup)
+			} else {
+				result[key] = value
+			}
+
+		case mapKeys[key]:
+			result[key] = mergeMap(base[key], value, key == "when")
+
+		default:
+			result[key] = value
+		}
+	}
+	return result
+}
+
+func mergeMap(base, override any, mergeEntries bool) any {
+	baseMap, baseOk := base.(map[string]any)
+	overrideMap, ok := override.(map[string]any)
+	if !baseOk || !ok || len(overrideMap) == 0 {
+		return override
+	}
+
+	result := maps.Clone(baseMap)
+	for key, value := range overrideMap {
+		baseEntry, baseEntryOk := result[key].(map[string]any)
+		entry, entryOk := value.(map[string]any)
+		switch {
+		case value == nil:
+			delete(result, key)
+		case mergeEntries && baseEntryOk && entryOk:
+			merged := maps.Clone(baseEntry)
+			maps.Copy(merged, entry)
+			result[key] = merged
+		default:
+			result[key] = value
+		}
+	}
+	return result
+}
+
+func mergeSteps(base, override []any) []any {
+	if len(base) == 0 {
+		return override
+	}
+
+	result := slices.Clone(base)
+
+	names := make(map[string]int, len(base))
+	for i, step := range base {
+		if name := stepName(step); name != "" {
+			if _, ok := names[name]; !ok {
+				names[name] = i
+			}
+		}
+	}
+
+	for _, step := range override {
+		i, ok := names[stepName(step)]
+		baseStep, baseOk := result[i].(map[string]any)
+		overrideStep, overrideOk := step.(map[string]any)
+		if ok && baseOk && overrideOk {
+			result[i] = mergeDocuments(baseStep, overrideStep)
+		} else {
+			result = append(result, step)
+		}
+	}
+
+	return result
+}
+
+func stepName(step any) string {
+	if step, ok := step.(map[string]any); ok {
+		name, _ := step["name"].(string)
+		return name
+	}
+	return ""
+}
+
+func asList(value any) []any {
+	list, _ := value.([]any)
+	return list
+}
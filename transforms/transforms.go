@@ -0,0 +1,128 @@
+package transforms
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type transform struct {
+	// whether the transform requires an argument
+	arg   bool
+	apply func(value string, arg string) (string, error)
+}
+
+var slugPattern = regexp.MustCompile(`[^a-z0-9]+`)
+
+var functions = map[string]transform{
+	"lower": {apply: func(value, _ string) (string, error) { return strings.ToLower(value), nil }},
+	"upper": {apply: func(value, _ string) (string, error) { return strings.ToUpper(value), nil }},
+	"trim":  {apply: func(value, _ string) (string, error) { return strings.TrimSpace(value), nil }},
+
+	"trimPrefix": {arg: true, apply: func(value, arg string) (string, error) { return strings.TrimPrefix(value, arg), nil }},
+	"trimSuffix": {arg: true, apply: func(value, arg string) (string, error) { return strings.TrimSuffix(value, arg), nil }},
+
+	// truncates to the given number of characters
+	"truncate": {arg: true, apply: func(value, arg string) (string, error) {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 0 {
+			return "", fmt.Errorf(`length must be a non-negative integer but is "%s"`, arg)
+		}
+		if utf8.RuneCountInString(value) <= n {
+			return value, nil
+		}
+		return string([]rune(value)[:n]), nil
+	}},
+
+	// lower case with every sequence of characters other than letters and digits replaced by a dash, e.g. for docker tags
+	"slug": {apply: func(value, _ string) (string, error) {
+		return strings.Trim(slugPattern.ReplaceAllString(strings.ToLower(value), "-"), "-"), nil
+	}},
+
+	"base64":    {apply: func(value, _ string) (string, error) { return base64.StdEncoding.EncodeToString([]byte(value)), nil }},
+	"base64url": {apply: func(value, _ string) (string, error) { return base64.URLEncoding.EncodeToString([]byte(value)), nil }},
+	"base64decode": {apply: func(value, _ string) (string, error) {
+		result, err := base64.StdEncoding.DecodeString(value)
+		return string(result), err
+	}},
+
+	"urlescape":  {apply: func(value, _ string) (string, error) { return url.QueryEscape(value), nil }},
+	"pathescape": {apply: func(value, _ string) (string, error) { return url.PathEscape(value), nil }},
+
+	"md5":    {apply: hash(func(b []byte) []byte { h := md5.Sum(b); return h[:] })},
+	"sha1":   {apply: hash(func(b []byte) []byte { h := sha1.Sum(b); return h[:] })},
+	"sha256": {apply: hash(func(b []byte) []byte { h := sha256.Sum256(b); return h[:] })},
+	"sha512": {apply: hash(func(b []byte) []byte { h := sha512.Sum512(b); return h[:] })},
+}
+
+func hash(sum func([]byte) []byte) func(value, _ string) (string, error) {
+	return func(value, _ string) (string, error) {
+		return hex.EncodeToString(sum([]byte(value))), nil
+	}
+}
+
+// Names returns the names of all built-in transforms.
+func Names() []string {
+	result := make([]string, 0, len(functions))
+	for name := range functions {
+		result = append(result, name)
+	}
+	slices.Sort(result)
+	return result
+}
+
+// Apply applies the transforms to the value in order.
+// A transform has the form "name" or "name:argument", e.g. "lower" or "truncate:8".
+func Apply(value string, transforms []string) (result string, err error) {
+	result = value
+	for _, expression := range transforms {
+		f, arg, err := parse(expression)
+		if err != nil {
+			return "", err
+		}
+		result, err = f.apply(result, arg)
+		if err != nil {
+			return "", fmt.Errorf(`error applying transform "%s" - %s`, expression, err)
+		}
+	}
+	return
+}
+
+func Validate(expression string) error {
+	f, arg, err := parse(expression)
+	if err != nil {
+		return err
+	}
+	// checks the argument, all transforms accept any value
+	if _, err := f.apply("", arg); err != nil {
+		return fmt.Errorf(`invalid transform "%s" - %s`, expression, err)
+	}
+	return nil
+}
+
+func parse(expression string) (transform, string, error) {
+	name, arg, hasArg := strings.Cut(expression, ":")
+
+	f, ok := functions[name]
+	if !ok {
+		return transform{}, "", fmt.Errorf(`unknown transform "%s" - expected one of %s`, name, strings.Join(Names(), ", "))
+	}
+	if f.arg && !hasArg {
+		return transform{}, "", fmt.Errorf(`transform "%s" requires an argument, e.g. "%s:<argument>"`, name, name)
+	}
+	if !f.arg && hasArg {
+		return transform{}, "", fmt.Errorf(`transform "%s" does not accept an argument`, name)
+	}
+
+	return f, arg, nil
+}
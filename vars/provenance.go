@@ -0,0 +1,169 @@
+package vars
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/reeveci/reeve-lib/filter"
+	"github.com/reeveci/reeve-lib/schema"
+)
+
+// Only one source provided the key
+const REASON_ONLY_CANDIDATE = "only candidate"
+
+// The value has a lower priority value than all other candidates
+const REASON_PRIORITY = "lowest priority"
+
+// Multiple candidates share the lowest priority value, the plugin name which sorts first wins
+const REASON_PLUGIN_NAME = "equal priority, plugin name sorts first"
+
+// Multiple candidates of the same plugin share the lowest priority value, the value which sorts first wins
+const REASON_VALUE = "equal priority and plugin, value sorts first"
+
+// Multiple unnamed sources share the lowest priority value, the source passed first wins
+const REASON_SOURCE_ORDER = "equal priority, source order"
+
+// EnvSource is the result of resolving env with a plugin.
+type EnvSource struct {
+	Plugin string
+	Env    map[string]schema.Env
+}
+
+type Candidate struct {
+	Plugin string
+	// Index of the source in the merged sources
+	Source int
+	Env    schema.Env
+}
+
+// Provenance describes where a merged env value came from.
+type Provenance struct {
+	Key    string
+	Plugin string
+	Env    schema.Env
+	// Candidates which lost against the chosen value, best first
+	Overridden []Candidate
+	Reason     string
+}
+
+type MergeResult struct {
+	Env        map[string]schema.Env
+	Provenance map[string]Provenance
+}
+
+// MergeEnvSources works like MergeOptionalEnv, but records where every value came from.
+// Candidates are ordered by priority, then by plugin name and finally by value,
+// so the result does not depend on the order of the sources.
+func MergeEnvSources(keys, optional []string, sources ...EnvSource) (MergeResult, error) {
+	return mergeSources(keys, optional, sources, true)
+}
+
+// mergeSources breaks ties by plugin name and value if byName is set and by source order otherwise.
+func mergeSources(keys, optional []string, sources []EnvSource, byName bool) (result MergeResult, err error) {
+	result.Env = make(map[string]schema.Env, len(keys))
+	result.Provenance = make(map[string]Provenance, len(keys))
+
+	missing := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		if _, ok := result.Provenance[key]; ok {
+			continue
+		}
+
+		candidates := make([]Candidate, 0, len(sources))
+		for i, source := range sources {
+			if value, ok := source.Env[key]; ok {
+				candidates = append(candidates, Candidate{Plugin: source.Plugin, Source: i, Env: value})
+			}
+		}
+		if len(candidates) == 0 {
+			if !slices.Contains(optional, key) {
+				missing = append(missing, key)
+			}
+			continue
+		}
+
+		slices.SortStableFunc(candidates, func(a, b Candidate) int {
+			if !byName {
+				return cmp.Compare(a.Env.Priority, b.Env.Priority)
+			}
+			return cmp.Or(
+				cmp.Compare(a.Env.Priority, b.Env.Priority),
+				strings.Compare(a.Plugin, b.Plugin),
+				strings.Compare(a.Env.Value, b.Env.Value),
+				compareBool(a.Env.Secret, b.Env.Secret),
+			)
+		})
+
+		provenance := Provenance{
+			Key:        key,
+			Plugin:     candidates[0].Plugin,
+			Env:        candidates[0].Env,
+			Overridden: candidates[1:],
+			Reason:     REASON_ONLY_CANDIDATE,
+		}
+		if len(candidates) > 1 {
+			switch {
+			case candidates[1].Env.Priority != candidates[0].Env.Priority:
+				provenance.Reason = REASON_PRIORITY
+			case !byName:
+				provenance.Reason = REASON_SOURCE_ORDER
+			case candidates[1].Plugin != candidates[0].Plugin:
+				provenance.Reason = REASON_PLUGIN_NAME
+			default:
+				provenance.Reason = REASON_VALUE
+			}
+		}
+
+		result.Env[key] = provenance.Env
+		result.Provenance[key] = provenance
+	}
+
+	if len(missing) > 0 {
+		err = fmt.Errorf("missing env %s", strings.Join(missing, ", "))
+	}
+	return
+}
+
+// Report describes the origin of all merged values sorted by key.
+// Secret values are replaced by filter.MASK.
+func (r MergeResult) Report() string {
+	keys := make([]string, 0, len(r.Provenance))
+	for key := range r.Provenance {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	var result strings.Builder
+	for _, key := range keys {
+		provenance := r.Provenance[key]
+		fmt.Fprintf(&result, "%s=%s from %s (priority %v, %s)\n", key, reportValue(provenance.Env), provenance.Plugin, provenance.Env.Priority, provenance.Reason)
+		for _, candidate := range provenance.Overridden {
+			fmt.Fprintf(&result, "  overrides %s from %s (priority %v)\n", reportValue(candidate.Env), candidate.Plugin, candidate.Env.Priority)
+		}
+	}
+	return result.String()
+}
+
+// compareBool orders secret values first, so that equal values are never reported as plain text.
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return -1
+	default:
+		return 1
+	}
+}
+
+func reportValue(env schema.Env) string {
+	if env.Secret {
+		return filter.MASK
+	}
+	return fmt.Sprintf("%q", env.Value)
+}
@@ -1,7 +1,7 @@
 package vars
 
 import (
-	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/reeveci/reeve-lib/schema"
@@ -10,11 +10,30 @@ import (
 type PipelineEnvBundle struct {
 	Env                       []string
 	PipelineEnv, RemainingEnv []string
+	// Keys which are only referenced by params with a fallback or default and may therefore be missing.
+	// They are not part of Env or RemainingEnv, so merging those never fails because of them.
+	// To use provided values, resolve them as well and merge with MergeOptionalEnv.
+	OptionalEnv []string
 }
 
 func FindAllEnv(pipeline schema.Pipeline) (result PipelineEnvBundle) {
 	pipelineResults := make(map[string]bool)
 	remainingResults := make(map[string]bool)
+	requiredResults := make(map[string]bool)
+	optionalResults := make(map[string]bool)
+
+	addRunConfig := func(config schema.RunConfig) {
+		optional := config.GetOptionalEnv()
+		for _, key := range optional {
+			optionalResults[key] = true
+		}
+		for _, key := range config.GetEnv() {
+			remainingResults[key] = true
+			if !slices.Contains(optional, key) {
+				requiredResults[key] = true
+			}
+		}
+	}
 
 	for key, condition := range pipeline.When {
 		if key == "" {
@@ -40,9 +59,7 @@ func FindAllEnv(pipeline schema.Pipeline) (result PipelineEnvBundle) {
 		}
 	}
 
-	for _, key := range pipeline.Setup.GetEnv() {
-		remainingResults[key] = true
-	}
+	addRunConfig(pipeline.Setup.RunConfig)
 
 	for _, step := range pipeline.Steps {
 		for key, condition := range step.When {
@@ -54,28 +71,38 @@ func FindAllEnv(pipeline schema.Pipeline) (result PipelineEnvBundle) {
 				envKey := strings.TrimPrefix(key, schema.ENV_PREFIX)
 				if envKey != "" {
 					remainingResults[envKey] = true
+					requiredResults[envKey] = true
 				}
 			}
 
 			for _, key := range condition.IncludeEnv {
 				if key != "" {
 					remainingResults[key] = true
+					requiredResults[key] = true
 				}
 			}
 			for _, key := range condition.ExcludeEnv {
 				if key != "" {
 					remainingResults[key] = true
+					requiredResults[key] = true
 				}
 			}
 		}
 
-		for _, key := range step.GetEnv() {
-			remainingResults[key] = true
-		}
+		addRunConfig(step.RunConfig)
 	}
 
 	for key := range pipelineResults {
 		delete(remainingResults, key)
+		requiredResults[key] = true
+	}
+
+	result.OptionalEnv = make([]string, 0, len(optionalResults))
+	for key := range optionalResults {
+		if !requiredResults[key] {
+			result.OptionalEnv = append(result.OptionalEnv, key)
+			delete(remainingResults, key)
+		}
 	}
 
 	result.Env = make([]string, 0, len(pipelineResults)+len(remainingResults))
@@ -89,37 +116,25 @@ func FindAllEnv(pipeline schema.Pipeline) (result PipelineEnvBundle) {
 		result.Env = append(result.Env, key)
 		result.RemainingEnv = append(result.RemainingEnv, key)
 	}
-
 	return
 }
 
+// MergeEnv merges the env provided by plugins and fails if any of keys is missing.
+// Keys listed in PipelineEnvBundle.OptionalEnv are not part of the other key lists, so they never cause a failure.
 func MergeEnv(keys []string, envs ...map[string]schema.Env) (result map[string]schema.Env, err error) {
-	result = make(map[string]schema.Env, len(keys))
-
-	for _, env := range envs {
-		if len(env) > 0 {
-			for _, key := range keys {
-				if key != "" {
-					existing, existingOk := result[key]
-					value, ok := env[key]
-					if ok && (!existingOk || value.Priority < existing.Priority) {
-						result[key] = value
-					}
-				}
-			}
-		}
-	}
+	return MergeOptionalEnv(keys, nil, envs...)
+}
 
-	missing := make([]string, 0, len(keys))
-	for _, key := range keys {
-		if _, ok := result[key]; !ok {
-			missing = append(missing, key)
-		}
-	}
-	if len(missing) > 0 {
-		err = fmt.Errorf("missing env %s", strings.Join(missing, ", "))
-		return
+// MergeOptionalEnv works like MergeEnv, but does not fail if keys listed in optional are missing.
+//
+// The value with the lowest priority wins, on equal priority the env passed first wins.
+// Use MergeEnvSources to break ties independently of the order of the sources.
+func MergeOptionalEnv(keys, optional []string, envs ...map[string]schema.Env) (result map[string]schema.Env, err error) {
+	sources := make([]EnvSource, len(envs))
+	for i, env := range envs {
+		sources[i] = EnvSource{Env: env}
 	}
 
-	return
+	merged, err := mergeSources(keys, optional, sources, false)
+	return merged.Env, err
 }